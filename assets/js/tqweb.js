@@ -0,0 +1,235 @@
+// Let htmx swap in the error fragments rendered for unprocessable input.
+document.addEventListener("htmx:beforeSwap", function (evt) {
+  if (evt.detail.xhr.status === 422) {
+    evt.detail.shouldSwap = true;
+    evt.detail.isError = false;
+  }
+});
+
+// Hand TOML files dropped on the input panel over to its upload field.
+document.addEventListener("dragover", function (evt) {
+  if (evt.target.closest && evt.target.closest("[data-toml-drop]")) {
+    evt.preventDefault();
+  }
+});
+document.addEventListener("drop", function (evt) {
+  var panel = evt.target.closest && evt.target.closest("[data-toml-drop]");
+  if (!panel || evt.dataTransfer.files.length === 0) {
+    return;
+  }
+  evt.preventDefault();
+  var field = panel.querySelector("input[type=file]");
+  field.files = evt.dataTransfer.files;
+  field.dispatchEvent(new Event("change", { bubbles: true }));
+});
+
+// Keyboard shortcuts and the command palette. The commands and their
+// shortcuts come from the data-command and data-shortcut attributes rendered
+// from the server-side registry; this script only knows how to run them.
+(function () {
+  function playground() {
+    return document.querySelector('form[hx-post="/api/v1/live"]');
+  }
+
+  // onPlayground runs the action against the playground form, going to the
+  // playground first when the current page has none.
+  function onPlayground(action) {
+    return function () {
+      var form = playground();
+      if (!form) {
+        window.location.href = "/";
+        return;
+      }
+      action(form);
+    };
+  }
+
+  function notify(message) {
+    var note = document.createElement("div");
+    note.className = "notification is-info is-light";
+    note.setAttribute("role", "status");
+    note.style.position = "fixed";
+    note.style.bottom = "1rem";
+    note.style.right = "1rem";
+    note.style.zIndex = "50";
+    note.textContent = message;
+    document.body.appendChild(note);
+    setTimeout(function () { note.remove(); }, 3000);
+  }
+
+  function restore(form, snippet) {
+    form.querySelector("#tqQuery").value = snippet.query;
+    form.querySelector("#tomlData").value = snippet.input;
+    var flags = {
+      tablesInline: snippet.options.tablesInline,
+      arraysMultiline: snippet.options.arraysMultiline,
+      indentTables: snippet.options.indentTables,
+    };
+    Object.keys(flags).forEach(function (name) {
+      var box = form.querySelector('input[name="' + name + '"]');
+      if (box) {
+        box.checked = !!flags[name];
+      }
+    });
+    form.querySelector("#tqQuery").dispatchEvent(new Event("input", { bubbles: true }));
+  }
+
+  var historyIndex = -1;
+
+  function stepHistory(step) {
+    return onPlayground(function (form) {
+      fetch("/api/v1/history")
+        .then(function (resp) { return resp.json(); })
+        .then(function (entries) {
+          if (entries.length === 0) {
+            notify("The history is empty.");
+            return;
+          }
+          historyIndex = Math.min(Math.max(historyIndex + step, 0), entries.length - 1);
+          return fetch("/api/v1/history/" + entries[historyIndex].id)
+            .then(function (resp) { return resp.json(); })
+            .then(function (snippet) { restore(form, snippet); });
+        });
+    });
+  }
+
+  var commands = {
+    "run": onPlayground(function (form) {
+      form.querySelector('button[name="run"]').click();
+    }),
+    "format-query": onPlayground(function (form) {
+      var field = document.activeElement;
+      if (!field || field.name !== "tqQuery") {
+        field = form.querySelector("#tqQuery");
+      }
+      var body = new URLSearchParams({ tqQuery: field.value });
+      fetch("/api/v1/query/format", { method: "POST", body: body }).then(function (resp) {
+        if (!resp.ok) {
+          notify("The query is not valid.");
+          return;
+        }
+        return resp.text().then(function (query) {
+          field.value = query;
+          field.dispatchEvent(new Event("input", { bubbles: true }));
+        });
+      });
+    }),
+    "share": onPlayground(function (form) {
+      fetch("/api/v1/snippets", { method: "POST", body: new FormData(form) })
+        .then(function (resp) { return resp.json(); })
+        .then(function (snippet) {
+          var url = window.location.origin + snippet.url;
+          if (navigator.clipboard) {
+            navigator.clipboard.writeText(url);
+          }
+          notify("Link copied: " + url);
+        });
+    }),
+    "history-previous": stepHistory(1),
+    "history-next": stepHistory(-1),
+    "add-query": onPlayground(function (form) {
+      form.querySelector('[hx-get="/queries/new"]').click();
+    }),
+    "palette": function () {
+      var palette = document.querySelector("[data-palette]");
+      palette.classList.add("is-active");
+      var filter = palette.querySelector("[data-palette-filter]");
+      filter.value = "";
+      filterPalette(palette, "");
+      filter.focus();
+    },
+  };
+
+  function closePalette() {
+    var palette = document.querySelector("[data-palette].is-active");
+    if (palette) {
+      palette.classList.remove("is-active");
+    }
+  }
+
+  function filterPalette(palette, text) {
+    text = text.toLowerCase();
+    palette.querySelectorAll("[data-palette-items] li").forEach(function (item) {
+      item.hidden = item.textContent.toLowerCase().indexOf(text) < 0;
+    });
+  }
+
+  function run(id) {
+    var command = commands[id];
+    if (!command) {
+      console.warn("tqweb: no handler for command " + id);
+      return;
+    }
+    closePalette();
+    command();
+  }
+
+  // combo spells the pressed keys the way the registry writes shortcuts.
+  function combo(evt) {
+    var keys = [];
+    if (evt.ctrlKey || evt.metaKey) {
+      keys.push("Ctrl");
+    }
+    if (evt.altKey) {
+      keys.push("Alt");
+    }
+    if (evt.shiftKey) {
+      keys.push("Shift");
+    }
+    keys.push(evt.key.length === 1 ? evt.key.toUpperCase() : evt.key);
+    return keys.join("+");
+  }
+
+  document.addEventListener("keydown", function (evt) {
+    var palette = document.querySelector("[data-palette].is-active");
+    if (palette && evt.key === "Escape") {
+      closePalette();
+      return;
+    }
+    if (palette && evt.key === "Enter" && evt.target.matches("[data-palette-filter]")) {
+      evt.preventDefault();
+      var first = palette.querySelector("[data-palette-items] li:not([hidden]) a");
+      if (first) {
+        first.click();
+      }
+      return;
+    }
+    var pressed = combo(evt);
+    var bound = document.querySelector('[data-command][data-shortcut="' + pressed + '"]');
+    if (bound) {
+      evt.preventDefault();
+      run(bound.getAttribute("data-command"));
+    }
+  });
+
+  document.addEventListener("input", function (evt) {
+    if (evt.target.matches("[data-palette-filter]")) {
+      filterPalette(evt.target.closest("[data-palette]"), evt.target.value);
+    }
+  });
+
+  document.addEventListener("click", function (evt) {
+    if (!evt.target.closest) {
+      return;
+    }
+    var command = evt.target.closest("[data-command]");
+    if (command) {
+      evt.preventDefault();
+      run(command.getAttribute("data-command"));
+      return;
+    }
+    if (evt.target.closest("[data-palette-close]") || evt.target.closest("[data-palette-items] a")) {
+      closePalette();
+    }
+  });
+})();
+
+// Link every PATTERN field of the playground to the diagnostics of its query,
+// which the results list renders with the ID diagnostics-n for the n-th one.
+htmx.onLoad(function () {
+  document.querySelectorAll('#queries input[name="tqQuery"]').forEach(function (field, i) {
+    if (i > 0) {
+      field.setAttribute("aria-describedby", "diagnostics-" + i);
+    }
+  });
+});
@@ -0,0 +1,316 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Options are the TOML output options of the tq program.
+type Options struct {
+	TablesInline    bool   `json:"tablesInline"`
+	ArraysMultiline bool   `json:"arraysMultiline"`
+	IndentSymbol    string `json:"indentSymbol"`
+	IndentTables    bool   `json:"indentTables"`
+}
+
+// Query is a named tq query.
+type Query struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+}
+
+// QueriesRequest runs the queries against the TOML document or the stored
+// document with the ID.
+type QueriesRequest struct {
+	TOMLData   string  `json:"tomlData"`
+	DocumentID string  `json:"documentId,omitempty"`
+	Queries    []Query `json:"queries"`
+	Options    Options `json:"options"`
+}
+
+// Result is the output of a single query or the error it failed with.
+type Result struct {
+	Name   string `json:"name"`
+	Query  string `json:"query"`
+	Output string `json:"output"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Err returns the failure of the query as a *QueryError or nil if the query
+// succeeded.
+func (r Result) Err() error {
+	if r.Error == "" {
+		return nil
+	}
+	return &QueryError{Name: r.Name, Query: r.Query, Detail: r.Error}
+}
+
+// QueryError is the failure of a single query among many. It matches
+// ErrUnprocessable with errors.Is.
+type QueryError struct {
+	Name   string
+	Query  string
+	Detail string
+}
+
+// Error implements the error interface.
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("tqweb: %s %q: %s", e.Name, e.Query, e.Detail)
+}
+
+// Is reports whether the target is ErrUnprocessable.
+func (e *QueryError) Is(target error) bool {
+	return target == ErrUnprocessable
+}
+
+// QueriesResponse holds the results of the queries in the order of the
+// request.
+type QueriesResponse struct {
+	Results []Result `json:"results"`
+}
+
+// Snippet is a saved query with its input and output options.
+type Snippet struct {
+	ID      string    `json:"id,omitempty"`
+	Title   string    `json:"title,omitempty"`
+	Query   string    `json:"query"`
+	Input   string    `json:"input"`
+	Options Options   `json:"options"`
+	Created time.Time `json:"created,omitempty"`
+
+	// URL is the path of the playground restoring the snippet. It is set
+	// on the snippets returned by SaveSnippet only.
+	URL string `json:"url,omitempty"`
+}
+
+// Document is a decoded TOML document stored on the server.
+type Document struct {
+	ID        string    `json:"id"`
+	Size      int       `json:"size"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// FormatResult is a normalized TOML document with the features of the source
+// lost in the round trip.
+type FormatResult struct {
+	Document string `json:"document"`
+	Losses   []struct {
+		Feature string `json:"feature"`
+		Path    string `json:"path,omitempty"`
+		Line    int    `json:"line,omitempty"`
+		Detail  string `json:"detail"`
+	} `json:"losses"`
+}
+
+// ConvertResult is a converted document with the values that could not be
+// converted.
+type ConvertResult struct {
+	Document string `json:"document"`
+	Issues   []struct {
+		Path   string `json:"path"`
+		Detail string `json:"detail"`
+	} `json:"issues"`
+}
+
+// StructsOptions configure the generated Go types.
+type StructsOptions struct {
+	Package  string `json:"package"`
+	Root     string `json:"root"`
+	Naming   string `json:"naming"`
+	Pointers bool   `json:"pointers"`
+}
+
+// StructsResult is the generated Go source.
+type StructsResult struct {
+	Source string `json:"source"`
+}
+
+// Violation is a value of the document violating a schema keyword.
+type Violation struct {
+	Path    string `json:"path"`
+	Keyword string `json:"keyword"`
+	Detail  string `json:"detail"`
+}
+
+// SchemaResult tells whether the document is valid against the schema.
+type SchemaResult struct {
+	Valid      bool        `json:"valid"`
+	Violations []Violation `json:"violations"`
+}
+
+// Difference is a change between two documents at the tq query path.
+type Difference struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	From any    `json:"from,omitempty"`
+	To   any    `json:"to,omitempty"`
+}
+
+// CacheStats are the result cache metrics of the server.
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Bytes     int    `json:"bytes"`
+}
+
+// Queries runs the named queries. Failing queries do not fail the call; see
+// Result.Err.
+func (c *Client) Queries(ctx context.Context, req QueriesRequest) (*QueriesResponse, error) {
+	var resp QueriesResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/queries", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Query runs a single query against the TOML document and returns its
+// output. A failing query returns a *QueryError.
+func (c *Client) Query(ctx context.Context, query, toml string, o Options) (string, error) {
+	resp, err := c.Queries(ctx, QueriesRequest{
+		TOMLData: toml,
+		Queries:  []Query{{Query: query}},
+		Options:  o,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Results) != 1 {
+		return "", fmt.Errorf("tqweb: got %d results for a single query", len(resp.Results))
+	}
+	r := resp.Results[0]
+	return r.Output, r.Err()
+}
+
+// ValidateQuery checks the syntax of the query.
+func (c *Client) ValidateQuery(ctx context.Context, query string) error {
+	return c.do(ctx, http.MethodPost, "/api/v1/query/validate", nil, url.Values{"tqQuery": {query}}, nil)
+}
+
+// FormatQuery rewrites the query in its canonical form.
+func (c *Client) FormatQuery(ctx context.Context, query string) (string, error) {
+	var resp struct {
+		Query string `json:"query"`
+	}
+	err := c.do(ctx, http.MethodPost, "/api/v1/query/format", nil, map[string]string{"query": query}, &resp)
+	return resp.Query, err
+}
+
+// ValidateTOML checks that the document is valid TOML.
+func (c *Client) ValidateTOML(ctx context.Context, toml string) error {
+	return c.do(ctx, http.MethodPost, "/api/v1/toml/validate", nil, url.Values{"tomlData": {toml}}, nil)
+}
+
+// FormatTOML normalizes the document with the output options.
+func (c *Client) FormatTOML(ctx context.Context, toml string, o Options) (*FormatResult, error) {
+	var resp FormatResult
+	req := map[string]any{"tomlData": toml, "options": o}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/toml/format", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Convert converts the input between the formats, toml or json. The options
+// apply to TOML output only.
+func (c *Client) Convert(ctx context.Context, from, to, input string, o Options) (*ConvertResult, error) {
+	var resp ConvertResult
+	query := url.Values{"from": {from}, "to": {to}}
+	req := map[string]any{"input": input, "options": o}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/convert", query, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GenerateStructs generates Go types for the document.
+func (c *Client) GenerateStructs(ctx context.Context, toml string, o StructsOptions) (*StructsResult, error) {
+	var resp StructsResult
+	req := map[string]any{"tomlData": toml, "options": o}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/toml/structs", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// InferSchema returns the JSON Schema inferred from the document.
+func (c *Client) InferSchema(ctx context.Context, toml string) (json.RawMessage, error) {
+	var resp json.RawMessage
+	req := map[string]any{"tomlData": toml}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/toml/schema", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ValidateSchema validates the document against the JSON Schema.
+func (c *Client) ValidateSchema(ctx context.Context, toml string, schema json.RawMessage) (*SchemaResult, error) {
+	var resp SchemaResult
+	req := map[string]any{"tomlData": toml, "schema": schema}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/toml/schema/validate", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Diff compares the documents, narrowed down by the query unless it is
+// empty.
+func (c *Client) Diff(ctx context.Context, left, right, query string) ([]Difference, error) {
+	var resp struct {
+		Differences []Difference `json:"differences"`
+	}
+	req := map[string]string{"left": left, "right": right, "query": query}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/diff", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Differences, nil
+}
+
+// StoreDocument decodes the document on the server and stores it, so that
+// queries can refer to it by its ID in QueriesRequest.DocumentID.
+func (c *Client) StoreDocument(ctx context.Context, toml string) (*Document, error) {
+	var resp Document
+	req := map[string]string{"tomlData": toml}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/documents", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// DeleteDocument removes the stored document.
+func (c *Client) DeleteDocument(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/documents/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// SaveSnippet saves the snippet and returns it with its ID and URL.
+func (c *Client) SaveSnippet(ctx context.Context, s Snippet) (*Snippet, error) {
+	var resp Snippet
+	req := map[string]any{"title": s.Title, "query": s.Query, "input": s.Input, "options": s.Options}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/snippets", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Snippet returns the saved snippet.
+func (c *Client) Snippet(ctx context.Context, id string) (*Snippet, error) {
+	var resp Snippet
+	if err := c.do(ctx, http.MethodGet, "/api/v1/snippets/"+url.PathEscape(id), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CacheStats returns the result cache metrics of the server.
+func (c *Client) CacheStats(ctx context.Context) (*CacheStats, error) {
+	var resp CacheStats
+	if err := c.do(ctx, http.MethodGet, "/api/v1/cache", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -0,0 +1,236 @@
+/*
+Package client calls a tqweb server over its HTTP API. It lets services run
+tq queries and work with TOML documents through a central tqweb instead of
+embedding tq themselves, and it has no dependencies beyond the standard
+library.
+
+Requests take a context and are retried when the server answers 429 Too Many
+Requests or 503 Service Unavailable, waiting as long as the Retry-After header
+asks. Failed requests return an *Error carrying the diagnostics of the server;
+it matches the sentinel errors of its status with errors.Is.
+*/
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRetries is the number of times a request is retried by default.
+	DefaultRetries = 3
+
+	// DefaultBackoff is the wait before the first retry when the server does
+	// not say how long to wait. The wait doubles with every retry.
+	DefaultBackoff = 500 * time.Millisecond
+
+	// MaxRetryAfter caps the wait the server can ask for.
+	MaxRetryAfter = time.Minute
+)
+
+var (
+	// ErrBadRequest is matched by errors for malformed requests.
+	ErrBadRequest = errors.New("bad request")
+
+	// ErrNotFound is matched by errors for unknown snippets, stored
+	// documents and routes.
+	ErrNotFound = errors.New("not found")
+
+	// ErrTooLarge is matched by errors for inputs over the server limits.
+	ErrTooLarge = errors.New("request entity too large")
+
+	// ErrUnprocessable is matched by errors for invalid queries and
+	// documents.
+	ErrUnprocessable = errors.New("unprocessable entity")
+
+	// ErrRateLimited is matched by errors for requests still rate limited
+	// after all retries.
+	ErrRateLimited = errors.New("too many requests")
+
+	// ErrUnavailable is matched by errors for requests the server was still
+	// unable to handle after all retries.
+	ErrUnavailable = errors.New("service unavailable")
+)
+
+var statusErrors = map[int]error{
+	http.StatusBadRequest:            ErrBadRequest,
+	http.StatusNotFound:              ErrNotFound,
+	http.StatusRequestEntityTooLarge: ErrTooLarge,
+	http.StatusUnprocessableEntity:   ErrUnprocessable,
+	http.StatusTooManyRequests:       ErrRateLimited,
+	http.StatusServiceUnavailable:    ErrUnavailable,
+}
+
+// Error is the error response of the server. The detail and the explanation
+// describe what is wrong with the query or the document for errors caused by
+// the request input.
+type Error struct {
+	StatusCode  int    `json:"-"`
+	Message     string `json:"message"`
+	Detail      string `json:"detail,omitempty"`
+	Explanation string `json:"explanation,omitempty"`
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("tqweb: %d %s", e.StatusCode, e.Message)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+// Is reports whether the target is the sentinel error of the status.
+func (e *Error) Is(target error) bool {
+	err, ok := statusErrors[e.StatusCode]
+	return ok && err == target
+}
+
+// Client calls the API of a tqweb server.
+type Client struct {
+	base    *url.URL
+	http    *http.Client
+	retries int
+	backoff time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient makes the client send requests with the HTTP client.
+func WithHTTPClient(h *http.Client) Option {
+	return func(c *Client) { c.http = h }
+}
+
+// WithRetries sets the number of times a rate limited or unavailable request
+// is retried. Zero turns retries off.
+func WithRetries(n int) Option {
+	return func(c *Client) { c.retries = max(n, 0) }
+}
+
+// WithBackoff sets the wait before the first retry when the server does not
+// say how long to wait.
+func WithBackoff(d time.Duration) Option {
+	return func(c *Client) { c.backoff = d }
+}
+
+// New returns a client of the tqweb server at the base URL, for example
+// http://localhost:8000.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("tqweb: invalid base URL %q", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	c := &Client{
+		base:    u,
+		http:    http.DefaultClient,
+		retries: DefaultRetries,
+		backoff: DefaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// do sends the request with the JSON body, or the form when the body is a
+// url.Values, and decodes the JSON response into out unless it is nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	var payload []byte
+	var contentType string
+	switch b := body.(type) {
+	case nil:
+	case url.Values:
+		payload, contentType = []byte(b.Encode()), "application/x-www-form-urlencoded"
+	default:
+		var err error
+		if payload, err = json.Marshal(b); err != nil {
+			return err
+		}
+		contentType = "application/json"
+	}
+	u := c.base.JoinPath(path)
+	u.RawQuery = query.Encode()
+	wait := c.backoff
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return err
+		}
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+		if !retry || attempt >= c.retries {
+			return decode(resp, out)
+		}
+		delay := retryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if delay < 0 {
+			delay = wait
+			wait *= 2
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// decode reads the response into out or into an *Error for error statuses.
+func decode(resp *http.Response, out any) error {
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		e := &Error{StatusCode: resp.StatusCode}
+		b, _ := io.ReadAll(resp.Body)
+		if err := json.Unmarshal(b, e); err != nil || e.Message == "" {
+			e.Message = http.StatusText(resp.StatusCode)
+		}
+		return e
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// retryAfter returns the wait the Retry-After header asks for, given in
+// seconds or as a date, capped at MaxRetryAfter. It is negative when the
+// header is missing or invalid.
+func retryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return -1
+	}
+	var d time.Duration
+	if s, err := strconv.Atoi(header); err == nil && s >= 0 {
+		d = time.Duration(s) * time.Second
+	} else if t, err := http.ParseTime(header); err == nil {
+		d = max(t.Sub(now), 0)
+	} else {
+		return -1
+	}
+	return min(d, MaxRetryAfter)
+}
@@ -0,0 +1,326 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mdm-code/tqweb/server"
+)
+
+// newTestClient returns a client of a tqweb server running in the test.
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	ts := httptest.NewServer(server.Server())
+	t.Cleanup(ts.Close)
+	c, err := New(ts.URL, WithHTTPClient(ts.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestQueries(t *testing.T) {
+	c := newTestClient(t)
+	resp, err := c.Queries(context.Background(), QueriesRequest{
+		TOMLData: "a = 1\nb = [1, 2]\n",
+		Queries:  []Query{{Name: "a", Query: `["a"]`}, {Name: "bad", Query: "["}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != 2 {
+		t.Fatalf("got %d results, want 2", len(resp.Results))
+	}
+	if r := resp.Results[0]; r.Output != "1\n" || r.Err() != nil {
+		t.Errorf("a: got %+v", r)
+	}
+	err = resp.Results[1].Err()
+	var qe *QueryError
+	if !errors.As(err, &qe) || qe.Name != "bad" || qe.Query != "[" {
+		t.Errorf("bad: got %v, want a *QueryError", err)
+	}
+	if !errors.Is(err, ErrUnprocessable) {
+		t.Errorf("bad: %v does not match ErrUnprocessable", err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	out, err := c.Query(ctx, `["b"]`, "b = [1, 2]\n", Options{ArraysMultiline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "[\n  1,\n  2\n]\n" {
+		t.Errorf("got %q", out)
+	}
+	_, err = c.Query(ctx, `["b"]`, "b = [", Options{})
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusUnprocessableEntity || e.Detail == "" {
+		t.Errorf("invalid TOML: got %v, want a 422 *Error with a detail", err)
+	}
+	if !errors.Is(err, ErrUnprocessable) || errors.Is(err, ErrBadRequest) {
+		t.Errorf("invalid TOML: %v matches the wrong sentinels", err)
+	}
+}
+
+func TestValidateAndFormatQuery(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	if err := c.ValidateQuery(ctx, `["a"]`); err != nil {
+		t.Errorf("ValidateQuery: %v", err)
+	}
+	err := c.ValidateQuery(ctx, "[")
+	var e *Error
+	if !errors.As(err, &e) || !errors.Is(err, ErrUnprocessable) || e.Explanation == "" {
+		t.Errorf("ValidateQuery([): got %#v", err)
+	}
+	q, err := c.FormatQuery(ctx, ` [ "a" ] `)
+	if err != nil || q != `["a"]` {
+		t.Errorf("FormatQuery = %q, %v", q, err)
+	}
+}
+
+func TestTOML(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	if err := c.ValidateTOML(ctx, "a = 1\n"); err != nil {
+		t.Errorf("ValidateTOML: %v", err)
+	}
+	if err := c.ValidateTOML(ctx, "a = "); !errors.Is(err, ErrUnprocessable) {
+		t.Errorf("ValidateTOML(a = ) = %v, want ErrUnprocessable", err)
+	}
+	f, err := c.FormatTOML(ctx, "# comment\na=1\n", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Document != "a = 1\n" || len(f.Losses) != 1 || f.Losses[0].Feature != "comments" {
+		t.Errorf("FormatTOML = %+v", f)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	r, err := c.Convert(ctx, "toml", "json", "a = 1\n", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]any
+	if err := json.Unmarshal([]byte(r.Document), &v); err != nil || v["a"] != 1.0 {
+		t.Errorf("Convert to JSON = %q, %v", r.Document, err)
+	}
+	r, err = c.Convert(ctx, "json", "toml", `{"a": 1}`, Options{})
+	if err != nil || r.Document != "a = 1\n" {
+		t.Errorf("Convert to TOML = %+v, %v", r, err)
+	}
+	if _, err := c.Convert(ctx, "yaml", "toml", "", Options{}); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Convert from YAML = %v, want ErrBadRequest", err)
+	}
+}
+
+func TestGenerateStructs(t *testing.T) {
+	c := newTestClient(t)
+	r, err := c.GenerateStructs(context.Background(), "name = \"a\"\n", StructsOptions{Package: "config", Root: "Config"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(r.Source, "package config") || !strings.Contains(r.Source, "type Config struct") {
+		t.Errorf("GenerateStructs = %q", r.Source)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	schema, err := c.InferSchema(ctx, "port = 8000\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := c.ValidateSchema(ctx, "port = 8001\n", schema)
+	if err != nil || !r.Valid {
+		t.Errorf("ValidateSchema(valid) = %+v, %v", r, err)
+	}
+	r, err = c.ValidateSchema(ctx, "port = \"x\"\n", schema)
+	if err != nil || r.Valid || len(r.Violations) == 0 {
+		t.Errorf("ValidateSchema(invalid) = %+v, %v", r, err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	c := newTestClient(t)
+	diffs, err := c.Diff(context.Background(), "a = 1\nb = 2\n", "a = 1\nb = 3\n", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || diffs[0].Path != `["b"]` || diffs[0].Op != "change" {
+		t.Errorf("Diff = %+v", diffs)
+	}
+}
+
+func TestDocuments(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	doc, err := c.StoreDocument(ctx, "a = 1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Queries(ctx, QueriesRequest{DocumentID: doc.ID, Queries: []Query{{Query: `["a"]`}}})
+	if err != nil || resp.Results[0].Output != "1\n" {
+		t.Errorf("Queries(document) = %+v, %v", resp, err)
+	}
+	if err := c.DeleteDocument(ctx, doc.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteDocument(ctx, doc.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteDocument(deleted) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSnippets(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+	saved, err := c.SaveSnippet(ctx, Snippet{Title: "t", Query: `["a"]`, Input: "a = 1\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.ID == "" || !strings.Contains(saved.URL, saved.ID) {
+		t.Errorf("SaveSnippet = %+v", saved)
+	}
+	got, err := c.Snippet(ctx, saved.ID)
+	if err != nil || got.Query != `["a"]` || got.Input != "a = 1\n" || got.Title != "t" {
+		t.Errorf("Snippet = %+v, %v", got, err)
+	}
+	_, err = c.Snippet(ctx, "missing")
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusNotFound || !errors.Is(err, ErrNotFound) {
+		t.Errorf("Snippet(missing) = %v, want a 404 *Error", err)
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CacheStats(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, u := range []string{"", "localhost:8000", "ftp://host", "http://"} {
+		if _, err := New(u); err == nil {
+			t.Errorf("New(%q) succeeded", u)
+		}
+	}
+}
+
+// flaky answers the first failures requests with the status and the
+// Retry-After header, and succeeds afterwards.
+func flaky(t *testing.T, failures int32, status int, retryAfter string) (*Client, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"hits":1}`))
+	}))
+	t.Cleanup(ts.Close)
+	c, err := New(ts.URL, WithHTTPClient(ts.Client()), WithBackoff(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, &calls
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+	}{
+		{"429 without Retry-After", http.StatusTooManyRequests, ""},
+		{"503 without Retry-After", http.StatusServiceUnavailable, ""},
+		{"429 with seconds", http.StatusTooManyRequests, "0"},
+		{"503 with a date", http.StatusServiceUnavailable, time.Now().Add(-time.Second).UTC().Format(http.TimeFormat)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, calls := flaky(t, 2, tt.status, tt.retryAfter)
+			stats, err := c.CacheStats(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Hits != 1 || calls.Load() != 3 {
+				t.Errorf("got %+v after %d calls", stats, calls.Load())
+			}
+		})
+	}
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	c, calls := flaky(t, 10, http.StatusTooManyRequests, "")
+	_, err := c.CacheStats(context.Background())
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("got %v, want ErrRateLimited", err)
+	}
+	if calls.Load() != DefaultRetries+1 {
+		t.Errorf("got %d calls, want %d", calls.Load(), DefaultRetries+1)
+	}
+	c, calls = flaky(t, 10, http.StatusServiceUnavailable, "")
+	c.retries = 0
+	if _, err := c.CacheStats(context.Background()); !errors.Is(err, ErrUnavailable) || calls.Load() != 1 {
+		t.Errorf("got %v after %d calls, want ErrUnavailable after 1", err, calls.Load())
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	c, calls := flaky(t, 1, http.StatusServiceUnavailable, "30")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.CacheStats(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("returned after %v", d)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("got %d calls, want 1", calls.Load())
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", -1},
+		{"soon", -1},
+		{"-1", -1},
+		{"0", 0},
+		{"2", 2 * time.Second},
+		{"3600", MaxRetryAfter},
+		{now.Add(10 * time.Second).Format(http.TimeFormat), 10 * time.Second},
+		{now.Add(-10 * time.Second).Format(http.TimeFormat), 0},
+		{now.Add(time.Hour).Format(http.TimeFormat), MaxRetryAfter},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.header, now); got != tt.want {
+			t.Errorf("retryAfter(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"flag"
+	"time"
+)
+
+func healthcheckFlags(fs *flag.FlagSet) func([]string) error {
+	connect := remoteFlags(fs, 5*time.Second)
+	return func(args []string) error {
+		if len(args) > 0 {
+			return errUsage
+		}
+		c, ctx, cancel, err := connect()
+		if err != nil {
+			return err
+		}
+		defer cancel()
+		_, err = c.CacheStats(ctx)
+		return err
+	}
+}
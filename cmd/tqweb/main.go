@@ -1,12 +1,139 @@
+/*
+Command tqweb runs the tqweb server and works with a running one.
+
+Usage:
+
+	tqweb <command> [flags] [arguments]
+
+Run tqweb help to list the commands and tqweb help <command> for the flags of
+a command. Without a command, tqweb serves as before.
+*/
 package main
 
 import (
-	"log"
-
-	"github.com/mdm-code/tqweb/server"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
 )
 
+// command is a subcommand of tqweb.
+type command struct {
+	name    string
+	args    string
+	summary string
+	flags   func(fs *flag.FlagSet) func(args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"serve", "", "Start the tqweb server.", serveFlags},
+		{"query", "<query> [file ...]", "Run a query on a running tqweb against the files or the standard input.", queryFlags},
+		{"healthcheck", "", "Exit with a non-zero status unless a running tqweb responds.", healthcheckFlags},
+		{"mcp", "", "Serve the tq tools over the Model Context Protocol on the standard input and output.", mcpFlags},
+		{"snippets", "list | export [id ...] | import [file ...] | delete id ...", "Manage the snippets saved in a snippet directory.", snippetsFlags},
+		{"version", "", "Print the version of tqweb.", versionFlags},
+		{"help", "[command]", "Show the help of tqweb or of the command.", helpFlags},
+	}
+}
+
+// errUsage is returned for invalid command lines after the usage is printed.
+var errUsage = errors.New("usage")
+
 func main() {
-	s := server.Server()
-	log.Fatal(s.Start("localhost:8000"))
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+// run runs the command line and returns the exit status.
+func run(args []string, stderr io.Writer) int {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	cmd, ok := lookup(name)
+	if !ok {
+		fmt.Fprintf(stderr, "tqweb: unknown command %q\n\n", name)
+		usage(stderr)
+		return 2
+	}
+	fs := newFlagSet(cmd, stderr)
+	exec := cmd.flags(fs)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if err := exec(fs.Args()); err != nil {
+		if errors.Is(err, errUsage) {
+			fs.Usage()
+			return 2
+		}
+		fmt.Fprintf(stderr, "tqweb %s: %v\n", cmd.name, err)
+		return 1
+	}
+	return 0
+}
+
+func lookup(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// newFlagSet returns the flag set of the command printing its usage line,
+// summary and flags on errors and -h.
+func newFlagSet(cmd command, w io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(w)
+	fs.Usage = func() {
+		line := "tqweb " + cmd.name + " [flags]"
+		if cmd.args != "" {
+			line += " " + cmd.args
+		}
+		fmt.Fprintf(w, "usage: %s\n\n%s\n", line, cmd.summary)
+		n := 0
+		fs.VisitAll(func(*flag.Flag) { n++ })
+		if n > 0 {
+			fmt.Fprintf(w, "\nflags:\n")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+// usage lists the commands.
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: tqweb <command> [flags] [arguments]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nRun tqweb help <command> for the flags of a command.\n")
+}
+
+func helpFlags(fs *flag.FlagSet) func([]string) error {
+	return func(args []string) error {
+		switch len(args) {
+		case 0:
+			usage(fs.Output())
+			return nil
+		case 1:
+			cmd, ok := lookup(args[0])
+			if !ok {
+				return fmt.Errorf("unknown command %q", args[0])
+			}
+			sub := newFlagSet(cmd, fs.Output())
+			cmd.flags(sub)
+			sub.Usage()
+			return nil
+		}
+		return errUsage
+	}
 }
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/mdm-code/tqweb/server/snippet"
+)
+
+// stdout runs f with the standard output redirected to a temporary file and
+// returns what f wrote.
+func stdout(t *testing.T, f func()) string {
+	t.Helper()
+	out, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	orig := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = orig }()
+	f()
+	b, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestUnknownCommand(t *testing.T) {
+	var stderr bytes.Buffer
+	if code := run([]string{"nope"}, &stderr); code != 2 {
+		t.Errorf("exit status %d, want 2", code)
+	}
+	for _, want := range []string{`unknown command "nope"`, "commands:", "snippets"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("%q missing from %q", want, stderr.String())
+		}
+	}
+}
+
+func TestHelp(t *testing.T) {
+	var stderr bytes.Buffer
+	if code := run([]string{"help", "snippets"}, &stderr); code != 0 {
+		t.Fatalf("exit status %d: %s", code, stderr.String())
+	}
+	for _, want := range []string{
+		"usage: tqweb snippets [flags] list | export [id ...]",
+		"Manage the snippets saved in a snippet directory.",
+		"-snippets",
+	} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("%q missing from %q", want, stderr.String())
+		}
+	}
+	stderr.Reset()
+	if code := run([]string{"help", "nope"}, &stderr); code != 1 {
+		t.Errorf("help of an unknown command: exit status %d, want 1", code)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	t.Setenv(snippetsEnv, "")
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"help", "query", "serve"},
+		{"snippets"},
+		{"snippets", "-snippets", dir, "list", "x"},
+		{"snippets", "-snippets", dir, "delete"},
+		{"snippets", "-snippets", dir, "rename", "x"},
+		{"snippets", "-unknown"},
+	} {
+		var stderr bytes.Buffer
+		if code := run(args, &stderr); code != 2 {
+			t.Errorf("%q: exit status %d, want 2", args, code)
+		}
+		if want := "usage: tqweb " + args[0]; !strings.Contains(stderr.String(), want) {
+			t.Errorf("%q: %q missing from %q", args, want, stderr.String())
+		}
+	}
+	var stderr bytes.Buffer
+	if code := run([]string{"snippets", "list"}, &stderr); code != 1 {
+		t.Errorf("without a snippet directory: exit status %d, want 1", code)
+	}
+}
+
+func TestSnippetsExportImport(t *testing.T) {
+	t.Setenv(snippetsEnv, "")
+	src, dst := t.TempDir(), t.TempDir()
+	store, err := snippet.NewDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []snippet.Snippet
+	for _, s := range []snippet.Snippet{
+		{Title: "servers", Query: `["servers"]`, Input: "[servers]\nip = 1\n"},
+		{Query: ".", Input: "a = 1\n", Options: eval.Options{TablesInline: true, IndentSymbol: "\t"}},
+	} {
+		saved, err := store.Put(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, saved)
+	}
+
+	var stderr bytes.Buffer
+	var code int
+	exported := stdout(t, func() { code = run([]string{"snippets", "-snippets", src, "export"}, &stderr) })
+	if code != 0 {
+		t.Fatalf("export: exit status %d: %s", code, stderr.String())
+	}
+	file := filepath.Join(t.TempDir(), "snippets.json")
+	if err := os.WriteFile(file, []byte(exported), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	imported := stdout(t, func() { code = run([]string{"snippets", "-snippets", dst, "import", file}, &stderr) })
+	if code != 0 {
+		t.Fatalf("import: exit status %d: %s", code, stderr.String())
+	}
+	if n := len(strings.Fields(imported)); n != len(want) {
+		t.Errorf("%d IDs printed on import, want %d: %q", n, len(want), imported)
+	}
+
+	got, err := snippet.NewDir(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range want {
+		s, err := got.Get(w.ID)
+		if err != nil {
+			t.Errorf("Get(%s) after import: %v", w.ID, err)
+			continue
+		}
+		if s.Title != w.Title || s.Query != w.Query || s.Input != w.Input || s.Options != w.Options {
+			t.Errorf("imported %+v, want %+v", s, w)
+		}
+	}
+}
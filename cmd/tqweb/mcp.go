@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/mdm-code/tqweb/server/i18n"
+	"github.com/mdm-code/tqweb/server/mcp"
+)
+
+func mcpFlags(fs *flag.FlagSet) func([]string) error {
+	lang := fs.String("lang", "en", "language of the error explanations")
+	return func(args []string) error {
+		if len(args) > 0 {
+			return errUsage
+		}
+		ctx := i18n.WithLanguage(context.Background(), i18n.Match(*lang, ""))
+		return mcp.New(buildVersion()).Serve(ctx, os.Stdin, os.Stdout)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mdm-code/tqweb/client"
+)
+
+func queryFlags(fs *flag.FlagSet) func([]string) error {
+	connect := remoteFlags(fs, 30*time.Second)
+	var o client.Options
+	fs.BoolVar(&o.TablesInline, "tables-inline", false, "write tables inline")
+	fs.BoolVar(&o.ArraysMultiline, "arrays-multiline", false, "write arrays on many lines")
+	fs.BoolVar(&o.IndentTables, "indent-tables", false, "indent nested tables")
+	fs.StringVar(&o.IndentSymbol, "indent-symbol", "", "string to indent with")
+	return func(args []string) error {
+		if len(args) == 0 {
+			return errUsage
+		}
+		query, files := args[0], args[1:]
+		if len(files) == 0 {
+			files = []string{"-"}
+		}
+		c, ctx, cancel, err := connect()
+		if err != nil {
+			return err
+		}
+		defer cancel()
+		for _, f := range files {
+			input, err := readInput(f)
+			if err != nil {
+				return err
+			}
+			out, err := c.Query(ctx, query, input, o)
+			if err != nil {
+				return fmt.Errorf("%s: %w", f, printError(os.Stderr, err))
+			}
+			if len(files) > 1 {
+				fmt.Printf("# %s\n", f)
+			}
+			fmt.Print(out)
+		}
+		return nil
+	}
+}
+
+// readInput reads the file or the standard input for -.
+func readInput(name string) (string, error) {
+	if name == "-" {
+		b, err := io.ReadAll(os.Stdin)
+		return string(b), err
+	}
+	b, err := os.ReadFile(name)
+	return string(b), err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mdm-code/tqweb/client"
+)
+
+// serverEnv names the environment variable with the default server URL.
+const serverEnv = "TQWEB_SERVER"
+
+// remoteFlags adds the flags of the commands calling a running tqweb and
+// returns the function connecting to it.
+func remoteFlags(fs *flag.FlagSet, timeout time.Duration) func() (*client.Client, context.Context, context.CancelFunc, error) {
+	addr := os.Getenv(serverEnv)
+	if addr == "" {
+		addr = "http://localhost:8000"
+	}
+	server := fs.String("server", addr, "URL of the tqweb server, defaults to $"+serverEnv+" if set")
+	wait := fs.Duration("timeout", timeout, "time to wait for the server")
+	retries := fs.Int("retries", client.DefaultRetries, "times to retry a rate limited or unavailable request")
+	return func() (*client.Client, context.Context, context.CancelFunc, error) {
+		c, err := client.New(*server, client.WithRetries(*retries))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), *wait)
+		return c, ctx, cancel, nil
+	}
+}
+
+// printError writes the error with the diagnostics of the server, if it has
+// any, and returns the error to exit with.
+func printError(w io.Writer, err error) error {
+	var e *client.Error
+	if errors.As(err, &e) && e.Detail != "" {
+		fmt.Fprintln(w, e.Detail)
+		if e.Explanation != "" {
+			fmt.Fprintln(w, e.Explanation)
+		}
+		return errors.New(e.Message)
+	}
+	var q *client.QueryError
+	if errors.As(err, &q) {
+		fmt.Fprintln(w, q.Detail)
+		return errors.New("invalid query")
+	}
+	return err
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/mdm-code/tqweb/server"
+)
+
+func serveFlags(fs *flag.FlagSet) func([]string) error {
+	addr := fs.String("addr", "localhost:8000", "address to listen on")
+	root := fs.String("root", "", "directory with TOML files to browse")
+	snippets := snippetsFlag(fs, "directory to save snippets in instead of memory")
+	frameAncestors := fs.String("frame-ancestors", "'self'", "space-separated sources allowed to frame embedded snippets")
+	return func(args []string) error {
+		if len(args) > 0 {
+			return errUsage
+		}
+		var opts []server.Option
+		if *root != "" {
+			opt, err := server.WithRoot(*root)
+			if err != nil {
+				return err
+			}
+			opts = append(opts, opt)
+		}
+		if *snippets != "" {
+			opt, err := server.WithSnippets(*snippets)
+			if err != nil {
+				return err
+			}
+			opts = append(opts, opt)
+		}
+		opt, err := server.WithFrameAncestors(*frameAncestors)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, opt)
+		return server.Server(opts...).Start(*addr)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mdm-code/tqweb/server/snippet"
+)
+
+// snippetsEnv names the environment variable with the default snippet
+// directory.
+const snippetsEnv = "TQWEB_SNIPPETS"
+
+// snippetsFlag defines the flag naming the snippet directory shared by the
+// serve and snippets commands.
+func snippetsFlag(fs *flag.FlagSet, usage string) *string {
+	return fs.String("snippets", os.Getenv(snippetsEnv), usage+", defaults to $"+snippetsEnv)
+}
+
+func snippetsFlags(fs *flag.FlagSet) func([]string) error {
+	dir := snippetsFlag(fs, "snippet directory the server saves to")
+	return func(args []string) error {
+		if len(args) == 0 {
+			return errUsage
+		}
+		if *dir == "" {
+			return fmt.Errorf("no snippet directory, set -snippets or $%s", snippetsEnv)
+		}
+		store, err := snippet.NewDir(*dir)
+		if err != nil {
+			return err
+		}
+		action, ids := args[0], args[1:]
+		switch action {
+		case "list":
+			if len(ids) > 0 {
+				return errUsage
+			}
+			return listSnippets(store)
+		case "export":
+			return exportSnippets(store, ids)
+		case "import":
+			return importSnippets(store, ids)
+		case "delete":
+			if len(ids) == 0 {
+				return errUsage
+			}
+			for _, id := range ids {
+				if err := store.Delete(id); err != nil {
+					return fmt.Errorf("%s: %w", id, err)
+				}
+			}
+			return nil
+		}
+		return errUsage
+	}
+}
+
+func listSnippets(store snippet.Store) error {
+	list, err := store.List()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCREATED\tTITLE\tQUERY")
+	for _, s := range list {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.ID, s.Created.Format(time.DateTime), s.Title, s.Query)
+	}
+	return w.Flush()
+}
+
+// exportSnippets writes the snippets with the IDs, or all of them, to the
+// standard output as a JSON array the import action reads.
+func exportSnippets(store snippet.Store, ids []string) error {
+	var list []snippet.Snippet
+	if len(ids) == 0 {
+		var err error
+		if list, err = store.List(); err != nil {
+			return err
+		}
+	}
+	for _, id := range ids {
+		s, err := store.Get(id)
+		if err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		list = append(list, s)
+	}
+	if list == nil {
+		list = []snippet.Snippet{}
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(list)
+}
+
+// importSnippets saves the snippets exported to the files, or to the
+// standard input, and prints their IDs. The IDs are derived from the
+// contents again, so they stay in line with the snippets saved by the
+// server.
+func importSnippets(store snippet.Store, files []string) error {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, f := range files {
+		input, err := readInput(f)
+		if err != nil {
+			return err
+		}
+		var list []snippet.Snippet
+		if err := json.Unmarshal([]byte(input), &list); err != nil {
+			return fmt.Errorf("%s: %w", f, err)
+		}
+		for _, s := range list {
+			s.ID = ""
+			saved, err := store.Put(s)
+			if err != nil {
+				return fmt.Errorf("%s: %w", f, err)
+			}
+			fmt.Println(saved.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// version is set at link time with -ldflags "-X main.version=v1.2.3". The
+// module version or the VCS revision of the build is used otherwise.
+var version = ""
+
+func versionFlags(fs *flag.FlagSet) func([]string) error {
+	return func(args []string) error {
+		if len(args) > 0 {
+			return errUsage
+		}
+		fmt.Printf("tqweb %s %s\n", buildVersion(), runtime.Version())
+		return nil
+	}
+}
+
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	var revision, modified string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			if s.Value == "true" {
+				modified = "-dirty"
+			}
+		}
+	}
+	if revision == "" {
+		return "devel"
+	}
+	return "devel-" + revision[:min(len(revision), 12)] + modified
+}
@@ -6,6 +6,9 @@ require (
 	github.com/a-h/templ v0.2.771
 	github.com/labstack/echo/v4 v4.12.0
 	github.com/mdm-code/tq v1.3.0
+	github.com/pelletier/go-toml/v2 v2.1.0
+	golang.org/x/net v0.28.0
+	golang.org/x/text v0.17.0
 )
 
 require (
@@ -14,13 +17,10 @@ require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/mdm-code/scanner v1.2.1 // indirect
-	github.com/pelletier/go-toml/v2 v2.1.0 // indirect
 	github.com/stretchr/testify v1.9.0 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasttemplate v1.2.2 // indirect
 	golang.org/x/crypto v0.26.0 // indirect
-	golang.org/x/net v0.28.0 // indirect
 	golang.org/x/sys v0.23.0 // indirect
-	golang.org/x/text v0.17.0 // indirect
 	golang.org/x/time v0.5.0 // indirect
 )
@@ -0,0 +1,148 @@
+/*
+Package batch runs a single tq query over many TOML documents. Documents are
+read and evaluated one at a time and each result is handed over to the caller
+as soon as it is ready, so a large batch never has to sit in memory as a
+whole. Every document is bounded by the size limit of uploads and a batch by
+the number of documents and of the entries of its zip archives.
+*/
+package batch
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path"
+	"strings"
+
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/mdm-code/tqweb/server/upload"
+)
+
+// MaxEntries is the number of TOML documents in a batch and of the entries
+// of each zip archive in it, TOML documents or not.
+const MaxEntries = 1000
+
+var (
+	// ErrNoDocuments is returned when a batch holds no TOML documents.
+	ErrNoDocuments = errors.New("no TOML documents in the batch")
+
+	// ErrTooManyEntries is returned for batches with more than MaxEntries
+	// documents and for zip archives with more than MaxEntries entries.
+	ErrTooManyEntries = fmt.Errorf("more than %d entries in the batch", MaxEntries)
+)
+
+// Entry is a single TOML document of a batch.
+type Entry struct {
+	Name string
+	Open func() (io.ReadCloser, error)
+}
+
+// Result is the outcome of running the query against a single document.
+type Result struct {
+	File   string `json:"file"`
+	Output string `json:"output"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Batch is the list of TOML documents a query is run against.
+type Batch struct {
+	Entries []Entry
+	closers []io.Closer
+}
+
+// Close releases the archives the batch entries are read from.
+func (b *Batch) Close() error {
+	var errs []error
+	for _, c := range b.closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// FromMultipart lists the TOML documents among the uploaded files. Zip
+// archives are expanded into the TOML documents they contain, and files
+// without the .toml extension are skipped.
+func FromMultipart(files []*multipart.FileHeader) (*Batch, error) {
+	b := &Batch{}
+	for _, fh := range files {
+		switch strings.ToLower(path.Ext(fh.Filename)) {
+		case ".toml":
+			b.Entries = append(b.Entries, Entry{
+				Name: fh.Filename,
+				Open: func() (io.ReadCloser, error) { return fh.Open() },
+			})
+		case ".zip":
+			if err := b.addZip(fh); err != nil {
+				b.Close()
+				return nil, fmt.Errorf("%s: %w", fh.Filename, err)
+			}
+		}
+		if len(b.Entries) > MaxEntries {
+			b.Close()
+			return nil, ErrTooManyEntries
+		}
+	}
+	if len(b.Entries) == 0 {
+		b.Close()
+		return nil, ErrNoDocuments
+	}
+	return b, nil
+}
+
+func (b *Batch) addZip(fh *multipart.FileHeader) error {
+	f, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	b.closers = append(b.closers, f)
+	r, err := zip.NewReader(f, fh.Size)
+	if err != nil {
+		return err
+	}
+	if len(r.File) > MaxEntries {
+		return ErrTooManyEntries
+	}
+	for _, zf := range r.File {
+		if zf.FileInfo().IsDir() || strings.ToLower(path.Ext(zf.Name)) != ".toml" {
+			continue
+		}
+		b.Entries = append(b.Entries, Entry{
+			Name: path.Join(fh.Filename, zf.Name),
+			Open: zf.Open,
+		})
+	}
+	return nil
+}
+
+// Run runs the query against every entry in turn and passes each result to
+// emit. Errors of individual documents are reported in their results; Run
+// itself only fails when emit does.
+func (b *Batch) Run(query string, o eval.Options, emit func(Result) error) error {
+	for _, e := range b.Entries {
+		r := Result{File: e.Name}
+		input, err := read(e)
+		if err == nil {
+			r.Output, err = eval.Run(query, input, o)
+		}
+		if err != nil {
+			r.Error = err.Error()
+		}
+		if err := emit(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read reads the document with the size limit of uploads, which also guards
+// against archives that expand to much more than they weigh.
+func read(e Entry) (string, error) {
+	rc, err := e.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	return upload.Read(rc)
+}
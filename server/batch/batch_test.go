@@ -0,0 +1,101 @@
+package batch_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mdm-code/tqweb/server/batch"
+	"github.com/mdm-code/tqweb/server/eval"
+)
+
+// archive returns a zip archive with the number of TOML documents and one
+// file of another kind.
+func archive(t *testing.T, documents int) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for i := range documents {
+		f, err := w.Create(fmt.Sprintf("%d.toml", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(f, "n = %d\n", i)
+	}
+	if _, err := w.Create("README"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// upload returns the headers of the files as a multipart form parses them.
+func upload(t *testing.T, files map[string][]byte) []*multipart.FileHeader {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for name, data := range files {
+		f, err := w.CreateFormFile("files", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	if err := req.ParseMultipartForm(32 << 20); err != nil {
+		t.Fatal(err)
+	}
+	return req.MultipartForm.File["files"]
+}
+
+func TestFromMultipart(t *testing.T) {
+	b, err := batch.FromMultipart(upload(t, map[string][]byte{
+		"a.toml":   []byte("n = -1\n"),
+		"b.zip":    archive(t, 2),
+		"notes.md": []byte("# notes\n"),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	var results []batch.Result
+	err = b.Run(`["n"]`, eval.Options{}, func(r batch.Result) error {
+		results = append(results, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	have := map[string]string{}
+	for _, r := range results {
+		have[r.File] = r.Output + r.Error
+	}
+	want := map[string]string{"a.toml": "-1\n", "b.zip/0.toml": "0\n", "b.zip/1.toml": "1\n"}
+	if fmt.Sprint(have) != fmt.Sprint(want) {
+		t.Errorf("results %v, want %v", have, want)
+	}
+}
+
+func TestFromMultipartTooManyEntries(t *testing.T) {
+	for name, files := range map[string]map[string][]byte{
+		"archive":  {"a.zip": archive(t, batch.MaxEntries)},
+		"archives": {"a.zip": archive(t, batch.MaxEntries/2), "b.zip": archive(t, batch.MaxEntries/2), "c.toml": nil},
+	} {
+		if _, err := batch.FromMultipart(upload(t, files)); !errors.Is(err, batch.ErrTooManyEntries) {
+			t.Errorf("%s: %v, want ErrTooManyEntries", name, err)
+		}
+	}
+	b, err := batch.FromMultipart(upload(t, map[string][]byte{"a.zip": archive(t, batch.MaxEntries-1)}))
+	if err != nil {
+		t.Fatalf("at the limit: %v", err)
+	}
+	b.Close()
+}
@@ -0,0 +1,101 @@
+package batch
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+)
+
+// Writer writes out batch results one by one as they come.
+type Writer interface {
+	Write(Result) error
+	Close() error
+}
+
+// NewWriter returns the writer for the given format: ndjson, json or csv. It
+// reports false for any other format.
+func NewWriter(format string, w io.Writer) (Writer, bool) {
+	switch format {
+	case "ndjson":
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, true
+	case "json":
+		return &jsonWriter{w: w, enc: json.NewEncoder(w)}, true
+	case "csv":
+		return &csvWriter{w: csv.NewWriter(w)}, true
+	}
+	return nil, false
+}
+
+// ndjsonWriter writes one JSON object per line.
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(r Result) error {
+	return w.enc.Encode(r)
+}
+
+func (w *ndjsonWriter) Close() error {
+	return nil
+}
+
+// jsonWriter writes a JSON array one element at a time.
+type jsonWriter struct {
+	w       io.Writer
+	enc     *json.Encoder
+	written bool
+}
+
+func (w *jsonWriter) Write(r Result) error {
+	sep := ","
+	if !w.written {
+		sep = "["
+		w.written = true
+	}
+	if _, err := io.WriteString(w.w, sep); err != nil {
+		return err
+	}
+	return w.enc.Encode(r)
+}
+
+func (w *jsonWriter) Close() error {
+	end := "]\n"
+	if !w.written {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(w.w, end)
+	return err
+}
+
+// csvWriter writes a CSV record per result preceded by a header.
+type csvWriter struct {
+	w       *csv.Writer
+	written bool
+}
+
+func (w *csvWriter) Write(r Result) error {
+	if err := w.header(); err != nil {
+		return err
+	}
+	if err := w.w.Write([]string{r.File, r.Output, r.Error}); err != nil {
+		return err
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (w *csvWriter) Close() error {
+	if err := w.header(); err != nil {
+		return err
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (w *csvWriter) header() error {
+	if w.written {
+		return nil
+	}
+	w.written = true
+	return w.w.Write([]string{"file", "output", "error"})
+}
@@ -0,0 +1,134 @@
+/*
+Package browse exposes the TOML files found under a root directory to tqweb.
+Every path received from a client is resolved relative to the root, and paths
+leading outside of it, be it with .. elements or through symbolic links, are
+rejected.
+*/
+package browse
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mdm-code/tqweb/server/batch"
+	"github.com/mdm-code/tqweb/server/upload"
+)
+
+// ErrOutsideRoot is returned for paths that do not resolve to a TOML file
+// under the root directory.
+var ErrOutsideRoot = errors.New("path outside of the root directory")
+
+// Tree is the tree of TOML files under a root directory.
+type Tree struct {
+	root string
+}
+
+// New returns the tree of TOML files under the root directory.
+func New(root string) (*Tree, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	real, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(real)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "browse", Path: root, Err: fs.ErrInvalid}
+	}
+	return &Tree{root: real}, nil
+}
+
+// Root returns the absolute path of the root directory.
+func (t *Tree) Root() string {
+	return t.root
+}
+
+// Files lists the slash-separated paths of all the TOML files under the root
+// directory. Hidden directories are skipped.
+func (t *Tree) Files() ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(t.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != t.root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.ToLower(filepath.Ext(p)) != ".toml" {
+			return nil
+		}
+		rel, err := filepath.Rel(t.root, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	return files, err
+}
+
+// Resolve returns the file system path of the TOML file under the root
+// directory given its slash-separated path relative to the root.
+func (t *Tree) Resolve(rel string) (string, error) {
+	if !fs.ValidPath(rel) || strings.ToLower(path.Ext(rel)) != ".toml" {
+		return "", ErrOutsideRoot
+	}
+	real, err := filepath.EvalSymlinks(filepath.Join(t.root, filepath.FromSlash(rel)))
+	if err != nil {
+		return "", err
+	}
+	within, err := filepath.Rel(t.root, real)
+	if err != nil || within == ".." || strings.HasPrefix(within, ".."+string(filepath.Separator)) {
+		return "", ErrOutsideRoot
+	}
+	return real, nil
+}
+
+// Open opens the TOML file under the root directory.
+func (t *Tree) Open(rel string) (io.ReadCloser, error) {
+	p, err := t.Resolve(rel)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+// Read reads the TOML file under the root directory.
+func (t *Tree) Read(rel string) (string, error) {
+	f, err := t.Open(rel)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return upload.Read(f)
+}
+
+// Batch returns all the TOML files of the tree as a batch to run a query
+// across.
+func (t *Tree) Batch() (*batch.Batch, error) {
+	files, err := t.Files()
+	if err != nil {
+		return nil, err
+	}
+	b := &batch.Batch{}
+	for _, f := range files {
+		b.Entries = append(b.Entries, batch.Entry{
+			Name: f,
+			Open: func() (io.ReadCloser, error) { return t.Open(f) },
+		})
+	}
+	return b, nil
+}
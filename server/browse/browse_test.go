@@ -0,0 +1,112 @@
+package browse_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/mdm-code/tqweb/server/browse"
+)
+
+// tree returns the tree of a temporary root directory holding a.toml and
+// dir/b.toml, and the path of outside.toml in a directory next to the root.
+func tree(t *testing.T) (*browse.Tree, string) {
+	t.Helper()
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	files := map[string]string{
+		filepath.Join(root, "a.toml"):            "a = 1\n",
+		filepath.Join(root, "dir", "b.toml"):     "b = 2\n",
+		filepath.Join(root, ".hidden", "c.toml"): "c = 3\n",
+		filepath.Join(base, "outside.toml"):      "secret = 4\n",
+	}
+	for p, data := range files {
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tr, err := browse.New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tr, filepath.Join(base, "outside.toml")
+}
+
+func TestResolve(t *testing.T) {
+	tr, _ := tree(t)
+	for _, rel := range []string{"a.toml", "dir/b.toml"} {
+		p, err := tr.Resolve(rel)
+		if err != nil {
+			t.Errorf("Resolve(%s) = %v", rel, err)
+			continue
+		}
+		if want := filepath.Join(tr.Root(), filepath.FromSlash(rel)); p != want {
+			t.Errorf("Resolve(%s) = %s, want %s", rel, p, want)
+		}
+	}
+	if _, err := tr.Resolve("missing.toml"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Resolve(missing.toml) = %v, want ErrNotExist", err)
+	}
+}
+
+func TestResolveRejectsPathsOutsideRoot(t *testing.T) {
+	tr, outside := tree(t)
+	for _, rel := range []string{
+		"../outside.toml",
+		"../x",
+		"dir/../../outside.toml",
+		"./a.toml",
+		outside,
+		"/a.toml",
+		"a.json",
+		"",
+	} {
+		if _, err := tr.Resolve(rel); !errors.Is(err, browse.ErrOutsideRoot) {
+			t.Errorf("Resolve(%q) = %v, want ErrOutsideRoot", rel, err)
+		}
+	}
+}
+
+func TestResolveSymlinks(t *testing.T) {
+	tr, outside := tree(t)
+	links := map[string]string{
+		"out.toml":     outside,
+		"dir/up.toml":  "../../outside.toml",
+		"in.toml":      "dir/b.toml",
+		"dir/sib.toml": "../a.toml",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(tr.Root(), filepath.FromSlash(name))); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+	for _, rel := range []string{"out.toml", "dir/up.toml"} {
+		if _, err := tr.Resolve(rel); !errors.Is(err, browse.ErrOutsideRoot) {
+			t.Errorf("Resolve(%s) = %v, want ErrOutsideRoot", rel, err)
+		}
+		if _, err := tr.Read(rel); !errors.Is(err, browse.ErrOutsideRoot) {
+			t.Errorf("Read(%s) = %v, want ErrOutsideRoot", rel, err)
+		}
+	}
+	for rel, want := range map[string]string{"in.toml": "b = 2\n", "dir/sib.toml": "a = 1\n"} {
+		if got, err := tr.Read(rel); err != nil || got != want {
+			t.Errorf("Read(%s) = %q, %v, want %q", rel, got, err, want)
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	tr, _ := tree(t)
+	files, err := tr.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.toml", "dir/b.toml"}; !slices.Equal(files, want) {
+		t.Errorf("Files() = %v, want %v", files, want)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Package cache keeps rendered query results in a size-bounded LRU cache. The
+results are keyed by a hash of the query, the input document, the encoder
+configuration and the output format, and expire after a fixed time to live.
+*/
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mdm-code/tq/toml"
+)
+
+const (
+	// DefaultMaxBytes is the default budget of the cached response bodies.
+	DefaultMaxBytes = 32 << 20
+
+	// DefaultTTL is the default time the results are cached for.
+	DefaultTTL = 5 * time.Minute
+)
+
+// Key identifies a cached result.
+type Key string
+
+// NewKey hashes the parts of a request that determine its result.
+func NewKey(query, input string, conf toml.GoTOMLConf, format string) Key {
+	h := sha256.New()
+	for _, part := range []string{query, input, fmt.Sprintf("%+v", conf.Encoder), format} {
+		// The length prefix keeps the parts from running into each other.
+		fmt.Fprintf(h, "%d:%s", len(part), part)
+	}
+	return Key(hex.EncodeToString(h.Sum(nil)))
+}
+
+// Entry is a cached response.
+type Entry struct {
+	ContentType string
+	Body        []byte
+	ETag        string
+}
+
+// NewEntry returns an entry with a strong ETag computed from the body.
+func NewEntry(contentType string, body []byte) Entry {
+	sum := sha256.Sum256(body)
+	return Entry{
+		ContentType: contentType,
+		Body:        body,
+		ETag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+	}
+}
+
+// Stats are the cache metrics.
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Bytes     int    `json:"bytes"`
+}
+
+type item struct {
+	key     Key
+	entry   Entry
+	expires time.Time
+}
+
+// Cache is an LRU cache of responses bounded by the total size of their
+// bodies. It is safe for concurrent use.
+type Cache struct {
+	mu       sync.Mutex
+	maxBytes int
+	ttl      time.Duration
+	order    *list.List
+	items    map[Key]*list.Element
+	stats    Stats
+}
+
+// New returns a cache holding at most maxBytes of response bodies for ttl.
+func New(maxBytes int, ttl time.Duration) *Cache {
+	return &Cache{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		order:    list.New(),
+		items:    make(map[Key]*list.Element),
+	}
+}
+
+// Get returns the entry cached under the key unless it has expired.
+func (c *Cache) Get(k Key) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[k]
+	if ok && time.Now().After(e.Value.(*item).expires) {
+		c.remove(e)
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		return Entry{}, false
+	}
+	c.stats.Hits++
+	c.order.MoveToFront(e)
+	return e.Value.(*item).entry, true
+}
+
+// Put caches the entry under the key evicting the least recently used
+// entries past the size budget. Entries larger than the budget are not
+// cached.
+func (c *Cache) Put(k Key, entry Entry) {
+	if len(entry.Body) > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[k]; ok {
+		c.remove(e)
+	}
+	it := &item{key: k, entry: entry, expires: time.Now().Add(c.ttl)}
+	c.items[k] = c.order.PushFront(it)
+	c.stats.Entries++
+	c.stats.Bytes += len(entry.Body)
+	for c.stats.Bytes > c.maxBytes {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// Stats returns the current cache metrics.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+func (c *Cache) remove(e *list.Element) {
+	it := c.order.Remove(e).(*item)
+	delete(c.items, it.key)
+	c.stats.Entries--
+	c.stats.Bytes -= len(it.entry.Body)
+}
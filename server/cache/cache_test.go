@@ -0,0 +1,104 @@
+package cache_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mdm-code/tq/toml"
+
+	"github.com/mdm-code/tqweb/server/cache"
+)
+
+func entry(body string) cache.Entry {
+	return cache.NewEntry("text/plain", []byte(body))
+}
+
+func TestEvictionByBytes(t *testing.T) {
+	c := cache.New(10, time.Minute)
+	c.Put("a", entry("aaaa"))
+	c.Put("b", entry("bbbb"))
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a is not cached")
+	}
+	c.Put("c", entry("cccc"))
+	if _, ok := c.Get("b"); ok {
+		t.Error("b, the least recently used entry, is not evicted")
+	}
+	for _, k := range []cache.Key{"a", "c"} {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("%s is evicted", k)
+		}
+	}
+	c.Put("large", entry(strings.Repeat("x", 11)))
+	if _, ok := c.Get("large"); ok {
+		t.Error("an entry larger than the budget is cached")
+	}
+	if s := c.Stats(); s.Bytes != 8 || s.Entries != 2 || s.Evictions != 1 {
+		t.Errorf("stats %+v", s)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	c := cache.New(10, time.Minute)
+	c.Put("a", entry("aaaa"))
+	c.Put("a", entry("aaaaaa"))
+	if e, ok := c.Get("a"); !ok || string(e.Body) != "aaaaaa" {
+		t.Errorf("Get() = %q, %v", e.Body, ok)
+	}
+	if s := c.Stats(); s.Bytes != 6 || s.Entries != 1 || s.Evictions != 0 {
+		t.Errorf("stats %+v", s)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	c := cache.New(10, -time.Second)
+	c.Put("a", entry("a"))
+	if _, ok := c.Get("a"); ok {
+		t.Error("an expired entry is returned")
+	}
+	if s := c.Stats(); s.Entries != 0 || s.Bytes != 0 || s.Misses != 1 {
+		t.Errorf("stats %+v", s)
+	}
+}
+
+func TestStats(t *testing.T) {
+	c := cache.New(100, time.Minute)
+	c.Get("a")
+	c.Put("a", entry("abc"))
+	c.Get("a")
+	c.Get("a")
+	want := cache.Stats{Hits: 2, Misses: 1, Entries: 1, Bytes: 3}
+	if s := c.Stats(); s != want {
+		t.Errorf("stats %+v, want %+v", s, want)
+	}
+}
+
+func TestETag(t *testing.T) {
+	a, b := entry("body"), entry("body")
+	if a.ETag != b.ETag {
+		t.Errorf("ETags of the same body differ: %s and %s", a.ETag, b.ETag)
+	}
+	if !strings.HasPrefix(a.ETag, `"`) || !strings.HasSuffix(a.ETag, `"`) || strings.HasPrefix(a.ETag, "W/") {
+		t.Errorf("%s is not a strong ETag", a.ETag)
+	}
+	if c := entry("other body"); c.ETag == a.ETag {
+		t.Error("ETags of different bodies are the same")
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	var conf toml.GoTOMLConf
+	k := cache.NewKey(".", "a = 1", conf, "json")
+	if k != cache.NewKey(".", "a = 1", conf, "json") {
+		t.Error("keys of the same request differ")
+	}
+	for _, other := range []cache.Key{
+		cache.NewKey(".a", " = 1", conf, "json"),
+		cache.NewKey(".", "a = 1", conf, "html:en"),
+	} {
+		if other == k {
+			t.Error("keys of different requests are the same")
+		}
+	}
+}
@@ -0,0 +1,288 @@
+/*
+Package codegen generates Go type definitions from TOML documents. The types
+carry toml struct tags so that go-toml v2 can decode the document into them.
+
+The types are derived from the JSON Schema inferred for the document. Tables
+become structs, arrays become slices and keys missing from some of the tables
+of an array become optional fields tagged with omitempty. TOML datetimes map
+to time.Time and the local datetime types of go-toml v2. Values of mixed types
+become any.
+*/
+package codegen
+
+import (
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/mdm-code/tqweb/server/schema"
+)
+
+// Naming styles of the generated identifiers.
+const (
+	// NamingGo spells common initialisms such as ID or URL in upper case
+	// the way Go code does.
+	NamingGo = "go"
+
+	// NamingCamel capitalizes every word of the key and nothing else.
+	NamingCamel = "camel"
+)
+
+// ErrOptions is returned for unknown naming styles and invalid package
+// names.
+var ErrOptions = errors.New("invalid options")
+
+var initialisms = map[string]bool{
+	"api": true, "cpu": true, "dns": true, "html": true, "http": true,
+	"https": true, "id": true, "ip": true, "json": true, "sql": true,
+	"ssh": true, "tcp": true, "tls": true, "toml": true, "udp": true,
+	"uri": true, "url": true, "uuid": true, "xml": true, "yaml": true,
+}
+
+// Options control the generated code.
+type Options struct {
+	Package  string `json:"package"`
+	Root     string `json:"root"`
+	Naming   string `json:"naming"`
+	Pointers bool   `json:"pointers"`
+}
+
+// Result is the generated source.
+type Result struct {
+	Source string `json:"source"`
+}
+
+// kind of a generated type.
+type kind int
+
+const (
+	scalar kind = iota
+	structure
+	slice
+)
+
+// node is a generated type.
+type node struct {
+	kind   kind
+	name   string
+	fields []field
+	elem   *node
+}
+
+// field is a field of a generated struct.
+type field struct {
+	name     string
+	key      string
+	node     *node
+	optional bool
+}
+
+type generator struct {
+	o       Options
+	names   map[string]bool
+	structs []*node
+	imports map[string]bool
+}
+
+// Generate returns the Go types of the TOML document.
+func Generate(input string, o Options) (Result, error) {
+	if o.Package == "" {
+		o.Package = "config"
+	}
+	if o.Root == "" {
+		o.Root = "Config"
+	}
+	if o.Naming == "" {
+		o.Naming = NamingGo
+	}
+	if o.Naming != NamingGo && o.Naming != NamingCamel {
+		return Result{}, fmt.Errorf("%w: unknown naming style %q", ErrOptions, o.Naming)
+	}
+	if !token.IsIdentifier(o.Package) {
+		return Result{}, fmt.Errorf("%w: invalid package name %q", ErrOptions, o.Package)
+	}
+	doc, err := eval.Decode(input)
+	if err != nil {
+		return Result{}, err
+	}
+	g := generator{o: o, names: make(map[string]bool), imports: make(map[string]bool)}
+	g.structure(schema.Infer(doc.Data()), g.identifier(o.Root), "")
+	src, err := g.source()
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Source: src}, nil
+}
+
+func (g *generator) node(s *schema.Schema, name, parent string) *node {
+	if s == nil || len(s.Type) != 1 {
+		if s != nil && len(s.Type) == 2 && slices.Equal(s.Type, schema.Types{"integer", "number"}) {
+			return g.scalar("float64")
+		}
+		return g.scalar("any")
+	}
+	switch s.Type[0] {
+	case "object":
+		if len(s.Properties) == 0 {
+			return g.scalar("map[string]any")
+		}
+		return g.structure(s, name, parent)
+	case "array":
+		elem := g.node(s.Items, name, parent)
+		return &node{kind: slice, elem: elem}
+	case "integer":
+		return g.scalar("int64")
+	case "number":
+		return g.scalar("float64")
+	case "boolean":
+		return g.scalar("bool")
+	}
+	switch s.Format {
+	case schema.DateTime:
+		g.imports["time"] = true
+		return g.scalar("time.Time")
+	case schema.Date:
+		g.imports["github.com/pelletier/go-toml/v2"] = true
+		return g.scalar("toml.LocalDate")
+	case schema.Time:
+		g.imports["github.com/pelletier/go-toml/v2"] = true
+		return g.scalar("toml.LocalTime")
+	case schema.LocalDateTime:
+		g.imports["github.com/pelletier/go-toml/v2"] = true
+		return g.scalar("toml.LocalDateTime")
+	}
+	return g.scalar("string")
+}
+
+func (g *generator) scalar(name string) *node {
+	return &node{kind: scalar, name: name}
+}
+
+// structure generates a struct named after the key of the table. The name of
+// the parent struct is prepended to names already taken.
+func (g *generator) structure(s *schema.Schema, name, parent string) *node {
+	n := &node{kind: structure, name: g.typeName(name, parent)}
+	g.structs = append(g.structs, n)
+	keys := make([]string, 0, len(s.Properties))
+	for k := range s.Properties {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	fieldNames := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		f := field{
+			name:     unique(g.identifier(k), fieldNames),
+			key:      k,
+			optional: !slices.Contains(s.Required, k),
+		}
+		f.node = g.node(s.Properties[k], f.name, n.name)
+		n.fields = append(n.fields, f)
+	}
+	return n
+}
+
+func (g *generator) typeName(name, parent string) string {
+	if !g.names[name] {
+		g.names[name] = true
+		return name
+	}
+	return unique(parent+name, g.names)
+}
+
+// unique returns the name or the name followed by the lowest number not
+// taken yet and marks it as taken.
+func unique(name string, taken map[string]bool) string {
+	n := name
+	for i := 2; taken[n]; i++ {
+		n = name + strconv.Itoa(i)
+	}
+	taken[n] = true
+	return n
+}
+
+// identifier turns a TOML key into an exported Go identifier.
+func (g *generator) identifier(key string) string {
+	words := strings.FieldsFunc(key, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, w := range words {
+		if g.o.Naming == NamingGo && initialisms[strings.ToLower(w)] {
+			b.WriteString(strings.ToUpper(w))
+			continue
+		}
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	id := b.String()
+	if id == "" {
+		return "Field"
+	}
+	if r := []rune(id)[0]; !unicode.IsUpper(r) {
+		return "X" + id
+	}
+	return id
+}
+
+// pointer reports whether the optional field is generated as a pointer.
+func (f field) pointer(pointers bool) bool {
+	return pointers && f.optional && f.node.kind != slice && f.node.name != "any" && f.node.name != "map[string]any"
+}
+
+func (f field) tag() string {
+	if f.optional {
+		return `toml:` + strconv.Quote(f.key+",omitempty")
+	}
+	return `toml:` + strconv.Quote(f.key)
+}
+
+func (n *node) expr() string {
+	if n.kind == slice {
+		return "[]" + n.elem.expr()
+	}
+	return n.name
+}
+
+func (g *generator) source() (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "package %s\n\n", g.o.Package)
+	var std, ext []string
+	for i := range g.imports {
+		if strings.Contains(i, ".") {
+			ext = append(ext, strconv.Quote(i))
+		} else {
+			std = append(std, strconv.Quote(i))
+		}
+	}
+	slices.Sort(std)
+	slices.Sort(ext)
+	switch {
+	case len(std) > 0 && len(ext) > 0:
+		fmt.Fprintf(&b, "import (\n%s\n\n%s\n)\n\n", strings.Join(std, "\n"), strings.Join(ext, "\n"))
+	case len(std)+len(ext) > 0:
+		fmt.Fprintf(&b, "import (\n%s\n)\n\n", strings.Join(append(std, ext...), "\n"))
+	}
+	for _, n := range g.structs {
+		fmt.Fprintf(&b, "type %s struct {\n", n.name)
+		for _, f := range n.fields {
+			t := f.node.expr()
+			if f.pointer(g.o.Pointers) {
+				t = "*" + t
+			}
+			fmt.Fprintf(&b, "%s %s `%s`\n", f.name, t, f.tag())
+		}
+		b.WriteString("}\n\n")
+	}
+	src, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return "", err
+	}
+	return string(src), nil
+}
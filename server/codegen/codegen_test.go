@@ -0,0 +1,169 @@
+package codegen_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	gotoml "github.com/pelletier/go-toml/v2"
+
+	"github.com/mdm-code/tqweb/server/codegen"
+)
+
+const sample = `title = "tqweb"
+owner_id = 7
+ratio = 0.5
+enabled = true
+released = 2024-05-01T10:30:00Z
+birthday = 1990-01-02
+alarm = 07:30:00
+meeting = 2024-05-01T09:00:00
+tags = ["a", "b"]
+matrix = [[1, 2], [3]]
+
+[server]
+host = "localhost"
+port = 8080
+
+[server.tls]
+cert = "cert.pem"
+
+[[servers]]
+name = "alpha"
+ip = "10.0.0.1"
+
+[[servers]]
+name = "beta"
+ip = "10.0.0.2"
+role = "backup"
+weight = 2
+
+[[servers.disks]]
+size = 100
+`
+
+// main decodes the TOML document on the standard input into the generated
+// types rejecting unknown keys and writes it back encoded from them.
+const main = `
+
+func main() {
+	var c Config
+	d := toml.NewDecoder(os.Stdin)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&c); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := toml.NewEncoder(os.Stdout).Encode(c); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+`
+
+// TestRoundTrip compiles the types generated for the sample and checks that
+// the sample decodes into them and encodes back to the same document.
+func TestRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles the generated code")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	for name, o := range map[string]codegen.Options{
+		"go":       {Package: "main"},
+		"pointers": {Package: "main", Naming: codegen.NamingCamel, Pointers: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r, err := codegen.Generate(sample, o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cmd := exec.Command(gobin, "run", ".")
+			cmd.Dir = module(t, program(r.Source))
+			cmd.Env = append(os.Environ(), "GOFLAGS=-mod=vendor", "GOWORK=off")
+			cmd.Stdin = strings.NewReader(sample)
+			var stdout, stderr bytes.Buffer
+			cmd.Stdout, cmd.Stderr = &stdout, &stderr
+			if err := cmd.Run(); err != nil {
+				t.Fatalf("%v: %s\n%s", err, stderr.String(), r.Source)
+			}
+			var want, have map[string]any
+			if err := gotoml.Unmarshal([]byte(sample), &want); err != nil {
+				t.Fatal(err)
+			}
+			if err := gotoml.Unmarshal(stdout.Bytes(), &have); err != nil {
+				t.Fatalf("%v: %s", err, stdout.String())
+			}
+			if !reflect.DeepEqual(have, want) {
+				t.Errorf("round trip:\n%s\nwant:\n%s", stdout.String(), sample)
+			}
+		})
+	}
+}
+
+// module writes the program to a module of its own in a temporary directory
+// and returns the directory. go-toml is vendored from the tqweb module, so
+// the program builds without fetching any modules.
+func module(t *testing.T, program string) string {
+	t.Helper()
+	const toml = "github.com/pelletier/go-toml/v2"
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, "vendor", "modules.txt"))
+	if err != nil {
+		t.Skipf("no vendored modules: %v", err)
+	}
+	// The vendored modules list the module of go-toml in a block starting
+	// with its path and version.
+	_, block, ok := strings.Cut(string(data), "# "+toml+" ")
+	if !ok {
+		t.Fatalf("%s is not vendored", toml)
+	}
+	if i := strings.Index(block, "\n# "); i >= 0 {
+		block = block[:i+1]
+	}
+	version, _, _ := strings.Cut(block, "\n")
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":             "module roundtrip\n\ngo 1.23\n\nrequire " + toml + " " + version + "\n",
+		"main.go":            program,
+		"vendor/modules.txt": "# " + toml + " " + block,
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	src := filepath.Join(root, "vendor", filepath.FromSlash(toml))
+	if err := os.CopyFS(filepath.Join(dir, "vendor", filepath.FromSlash(toml)), os.DirFS(src)); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// program turns the generated source into a program running main. The local
+// datetimes of the sample make the generated source import go-toml v2.
+func program(src string) string {
+	return strings.Replace(src, "package main\n", "package main\n\nimport (\n\"fmt\"\n\"os\"\n)\n", 1) + main
+}
+
+func TestGenerateOptions(t *testing.T) {
+	for _, o := range []codegen.Options{{Naming: "snake"}, {Package: "not a name"}} {
+		if _, err := codegen.Generate(sample, o); !errors.Is(err, codegen.ErrOptions) {
+			t.Errorf("Generate(%+v) = %v, want ErrOptions", o, err)
+		}
+	}
+}
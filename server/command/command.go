@@ -0,0 +1,83 @@
+/*
+Package command is the registry of the playground actions. The actions are
+rendered into the pages as data attributes, from which the browser script
+binds their keyboard shortcuts and builds the command palette, so the server
+and the client always agree on what actions exist.
+
+Shortcuts are written as modifiers followed by a key name as reported by
+KeyboardEvent.key, joined with plus signs, for example Ctrl+Shift+F or
+Alt+ArrowUp. Ctrl also matches the Command key on macOS.
+*/
+package command
+
+import "strings"
+
+// Command is a playground action.
+type Command struct {
+	ID          string
+	Title       string
+	Description string
+	Shortcut    string
+}
+
+var registry = []Command{
+	{
+		ID:          "run",
+		Title:       "Run",
+		Description: "Evaluate the queries and record them in the history.",
+		Shortcut:    "Ctrl+Enter",
+	},
+	{
+		ID:          "format-query",
+		Title:       "Format query",
+		Description: "Rewrite the focused query in its canonical form.",
+		Shortcut:    "Ctrl+Shift+F",
+	},
+	{
+		ID:          "share",
+		Title:       "Share",
+		Description: "Save the playground as a snippet and copy its link.",
+		Shortcut:    "Ctrl+S",
+	},
+	{
+		ID:          "history-previous",
+		Title:       "Previous history entry",
+		Description: "Restore the previous state from the history.",
+		Shortcut:    "Alt+ArrowUp",
+	},
+	{
+		ID:          "history-next",
+		Title:       "Next history entry",
+		Description: "Restore the next state from the history.",
+		Shortcut:    "Alt+ArrowDown",
+	},
+	{
+		ID:          "add-query",
+		Title:       "Add query",
+		Description: "Add another named query to the playground.",
+		Shortcut:    "Alt+N",
+	},
+	{
+		ID:          "palette",
+		Title:       "Command palette",
+		Description: "List every action, example and reference entry.",
+		Shortcut:    "Ctrl+K",
+	},
+}
+
+// All returns every registered command.
+func All() []Command {
+	return registry
+}
+
+var keyLabels = strings.NewReplacer(
+	"ArrowUp", "↑",
+	"ArrowDown", "↓",
+	"ArrowLeft", "←",
+	"ArrowRight", "→",
+)
+
+// Label returns the shortcut of the command in the form shown to users.
+func (c Command) Label() string {
+	return keyLabels.Replace(c.Shortcut)
+}
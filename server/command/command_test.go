@@ -0,0 +1,52 @@
+package command
+
+import (
+	"fmt"
+	"testing"
+)
+
+// check makes sure the IDs and shortcuts of the commands are unique.
+func check(cmds []Command) error {
+	ids := make(map[string]bool, len(cmds))
+	shortcuts := make(map[string]string, len(cmds))
+	for _, c := range cmds {
+		if ids[c.ID] {
+			return fmt.Errorf("command %q is defined twice", c.ID)
+		}
+		ids[c.ID] = true
+		if c.Shortcut == "" {
+			continue
+		}
+		if other, ok := shortcuts[c.Shortcut]; ok {
+			return fmt.Errorf("commands %q and %q share the shortcut %s", other, c.ID, c.Shortcut)
+		}
+		shortcuts[c.Shortcut] = c.ID
+	}
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	if err := check(All()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for name, cmds := range map[string][]Command{
+		"id":       {{ID: "run", Shortcut: "Ctrl+Enter"}, {ID: "run"}},
+		"shortcut": {{ID: "run", Shortcut: "Ctrl+Enter"}, {ID: "share", Shortcut: "Ctrl+Enter"}},
+	} {
+		if err := check(cmds); err == nil {
+			t.Errorf("the duplicate %s is not reported", name)
+		}
+	}
+	if err := check([]Command{{ID: "run"}, {ID: "share"}}); err != nil {
+		t.Errorf("commands without shortcuts: %v", err)
+	}
+}
+
+func TestLabel(t *testing.T) {
+	if l := (Command{Shortcut: "Alt+ArrowUp"}).Label(); l != "Alt+↑" {
+		t.Errorf("Label() = %q", l)
+	}
+}
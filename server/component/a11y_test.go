@@ -0,0 +1,389 @@
+package component_test
+
+// The checks below look for common accessibility problems in the HTML
+// rendered by tqweb: form controls without labels, labels without controls,
+// buttons and links without names, images without alternative text, unknown
+// ARIA roles, references to elements that do not exist, and results swapped in
+// by htmx outside of ARIA live regions. The checks are static, so they catch
+// what can be told from the markup alone and leave the rest to manual review.
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/a-h/templ"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/mdm-code/tqweb/server/batch"
+	"github.com/mdm-code/tqweb/server/codegen"
+	"github.com/mdm-code/tqweb/server/component"
+	"github.com/mdm-code/tqweb/server/convert"
+	"github.com/mdm-code/tqweb/server/diff"
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/mdm-code/tqweb/server/example"
+	"github.com/mdm-code/tqweb/server/filter"
+	"github.com/mdm-code/tqweb/server/format"
+	"github.com/mdm-code/tqweb/server/history"
+	"github.com/mdm-code/tqweb/server/i18n"
+	"github.com/mdm-code/tqweb/server/lesson"
+	"github.com/mdm-code/tqweb/server/route"
+	"github.com/mdm-code/tqweb/server/schema"
+	"github.com/mdm-code/tqweb/server/snippet"
+)
+
+// fragmentTargets are the IDs of the playground elements the fragments refer
+// to, and the IDs of diagnostics the playground refers to before any are
+// swapped in.
+var fragmentTargets = []string{"tqQuery", "tomlData", "output", "diagnostics", "diagnostics-0"}
+
+// TestAccessibility renders every page and the fragments swapped into them
+// in every supported language and checks the markup.
+func TestAccessibility(t *testing.T) {
+	examples, err := example.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lessons, err := lesson.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := []eval.Result{
+		{Name: "ok", Query: `["a"]`, Output: "1\n"},
+		{Name: "failed", Query: "[", Error: "unexpected end of query"},
+	}
+	pages := map[string]templ.Component{
+		"index":     component.Index(component.Playground{}),
+		"examples":  component.Examples(examples),
+		"reference": component.Reference(filter.All()),
+		"learn":     component.Lessons(lessons, nil),
+		"lesson":    component.Lesson(lessons[0], false),
+		"batch":     component.Batch("", nil),
+		"browse":    component.Browse(".", []string{"a.toml"}, "", nil),
+		"watch":     component.Watch("a.toml", ".", ""),
+		"diff":      component.Diff(component.DiffForm{}),
+		"format":    component.Format(),
+		"convert":   component.Convert(),
+		"schema":    component.Schema(),
+		"structs":   component.Structs(),
+		"docs":      component.APIDocs(route.Spec()),
+		"embed":     component.Embed(snippet.Snippet{ID: "a", Query: "."}, false, "/oembed"),
+	}
+	fragments := map[string]templ.Component{
+		"results": component.Results(results),
+		"error":   component.Error("Unprocessable entity", "detail"),
+		"history": component.History([]history.Entry{{ID: "a", Query: "."}}, true),
+		"formatted": component.Formatted(format.Result{
+			Document: "a = 1\n",
+			Losses:   []format.Loss{{Feature: format.Comments, Line: 1}},
+		}),
+		"converted": component.Converted(convert.Result{
+			Issues: []convert.Issue{{Path: ".", Detail: "detail"}},
+		}),
+		"generated":   component.Generated(codegen.Result{Source: "package config\n"}),
+		"violations":  component.Violations([]schema.Violation{{Path: ".", Keyword: "type"}}),
+		"differences": component.Differences([]diff.Difference{{Op: diff.Add, Path: ".", To: 1}}),
+		"matches": component.BrowseResults(".", []batch.Result{
+			{File: "a.toml", Output: "1\n"},
+			{File: "b.toml", Error: "detail"},
+		}),
+	}
+	for _, tag := range i18n.Supported {
+		ctx := i18n.WithLanguage(context.Background(), tag)
+		for name, page := range pages {
+			t.Run(tag.String()+"/"+name, func(t *testing.T) {
+				verify(t, ctx, page, Check)
+			})
+		}
+		for name, fragment := range fragments {
+			t.Run(tag.String()+"/"+name, func(t *testing.T) {
+				verify(t, ctx, fragment, CheckFragment)
+			})
+		}
+	}
+}
+
+func TestCheckFragment(t *testing.T) {
+	cases := map[string]string{
+		`<input id="q">`:                       "form control has no label",
+		`<label>Query</label>`:                 "label is not associated with a control",
+		`<label for="q">Query</label>`:         `label is for the missing element "q"`,
+		`<button></button>`:                    "button has no name",
+		`<a href="/"></a>`:                     "a has no name",
+		`<img src="a.png">`:                    "image has no alternative text",
+		`<div role="widget"></div>`:            `unknown role "widget"`,
+		`<p aria-describedby="help">Query</p>`: `aria-describedby refers to the missing element "help"`,
+		`<form hx-post="/" hx-target="#o"></form><div id="o"></div>`: `results are swapped into "o" outside of a live region`,
+	}
+	for markup, want := range cases {
+		problems, err := CheckFragment(strings.NewReader(markup))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(problems) != 1 || problems[0].Detail != want {
+			t.Errorf("CheckFragment(%q) = %v, want %q", markup, problems, want)
+		}
+	}
+	ok := `<label>Query <input id="q"></label><div aria-live="polite" id="o"></div>` +
+		`<form hx-post="/" hx-target="#o"><button>Run</button></form>`
+	if problems, err := CheckFragment(strings.NewReader(ok)); err != nil || len(problems) > 0 {
+		t.Errorf("CheckFragment(%q) = %v, %v", ok, problems, err)
+	}
+}
+
+func verify(t *testing.T, ctx context.Context, c templ.Component, check func(io.Reader, ...string) ([]Problem, error)) {
+	t.Helper()
+	var b bytes.Buffer
+	if err := c.Render(ctx, &b); err != nil {
+		t.Fatal(err)
+	}
+	problems, err := check(&b, fragmentTargets...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range problems {
+		t.Error(p)
+	}
+}
+
+// Problem is a single accessibility problem found in a document.
+type Problem struct {
+	Element string
+	Detail  string
+}
+
+func (p Problem) String() string {
+	return p.Element + ": " + p.Detail
+}
+
+// roles lists the ARIA roles the pages may use.
+var roles = map[string]bool{
+	"alert": true, "banner": true, "button": true, "complementary": true,
+	"contentinfo": true, "dialog": true, "group": true, "link": true,
+	"list": true, "listitem": true, "log": true, "main": true,
+	"navigation": true, "note": true, "presentation": true, "region": true,
+	"search": true, "status": true, "tab": true, "tablist": true,
+	"tabpanel": true,
+}
+
+// Check parses the HTML document and returns its accessibility problems.
+// References to element IDs listed in external are not reported missing;
+// they name elements swapped in later.
+func Check(r io.Reader, external ...string) ([]Problem, error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return check([]*html.Node{root}, external), nil
+}
+
+// CheckFragment is like Check for fragments of a document swapped into a
+// page by htmx.
+func CheckFragment(r io.Reader, external ...string) ([]Problem, error) {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(r, body)
+	if err != nil {
+		return nil, err
+	}
+	return check(nodes, external), nil
+}
+
+func check(nodes []*html.Node, external []string) []Problem {
+	c := checker{
+		ids:      make(map[string]*html.Node),
+		external: make(map[string]bool),
+		labelled: make(map[string]bool),
+	}
+	for _, id := range external {
+		c.external[id] = true
+	}
+	for _, n := range nodes {
+		c.collect(n)
+	}
+	for _, n := range nodes {
+		c.check(n, false)
+	}
+	return c.problems
+}
+
+type checker struct {
+	ids      map[string]*html.Node
+	external map[string]bool
+	labelled map[string]bool
+	problems []Problem
+}
+
+func (c *checker) exists(id string) bool {
+	return c.ids[id] != nil || c.external[id]
+}
+
+// collect records the IDs of all the elements and the controls labelled with
+// the for attribute.
+func (c *checker) collect(n *html.Node) {
+	if n.Type == html.ElementNode {
+		if id := attr(n, "id"); id != "" {
+			c.ids[id] = n
+		}
+		if n.Data == "label" {
+			if f := attr(n, "for"); f != "" {
+				c.labelled[f] = true
+			}
+		}
+	}
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		c.collect(ch)
+	}
+}
+
+func (c *checker) check(n *html.Node, inLabel bool) {
+	if n.Type == html.ElementNode {
+		c.element(n, inLabel)
+		inLabel = inLabel || n.Data == "label"
+	}
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		c.check(ch, inLabel)
+	}
+}
+
+func (c *checker) element(n *html.Node, inLabel bool) {
+	if role := attr(n, "role"); role != "" && !roles[role] {
+		c.report(n, fmt.Sprintf("unknown role %q", role))
+	}
+	for _, a := range []string{"aria-describedby", "aria-labelledby", "aria-controls"} {
+		for _, id := range strings.Fields(attr(n, a)) {
+			if !c.exists(id) {
+				c.report(n, fmt.Sprintf("%s refers to the missing element %q", a, id))
+			}
+		}
+	}
+	if target, ok := strings.CutPrefix(attr(n, "hx-target"), "#"); ok && has(n, "hx-post") {
+		swap := attr(n, "hx-swap")
+		if t := c.ids[target]; t != nil && swap != "outerHTML" && swap != "beforeend" && !live(t) {
+			c.report(n, fmt.Sprintf("results are swapped into %q outside of a live region", target))
+		}
+	}
+	named := has(n, "aria-label") || has(n, "aria-labelledby") || has(n, "title")
+	switch n.Data {
+	case "input":
+		switch attr(n, "type") {
+		case "hidden":
+			return
+		case "submit", "button", "reset":
+			if !named && attr(n, "value") == "" {
+				c.report(n, "button has no name")
+			}
+			return
+		}
+		fallthrough
+	case "select", "textarea":
+		if !named && !inLabel && !c.labelled[attr(n, "id")] {
+			c.report(n, "form control has no label")
+		}
+	case "button", "a":
+		if n.Data == "a" && !has(n, "href") {
+			return
+		}
+		if !named && strings.TrimSpace(text(n)) == "" {
+			c.report(n, n.Data+" has no name")
+		}
+	case "label":
+		if f := attr(n, "for"); f != "" && !c.exists(f) {
+			c.report(n, fmt.Sprintf("label is for the missing element %q", f))
+		}
+		if attr(n, "for") == "" && !hasControl(n) {
+			c.report(n, "label is not associated with a control")
+		}
+	case "img":
+		if !has(n, "alt") {
+			c.report(n, "image has no alternative text")
+		}
+	case "html":
+		if attr(n, "lang") == "" {
+			c.report(n, "document has no language")
+		}
+	}
+}
+
+// live reports whether the element is within an ARIA live region.
+func live(n *html.Node) bool {
+	for ; n != nil; n = n.Parent {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		if has(n, "aria-live") {
+			return attr(n, "aria-live") != "off"
+		}
+		switch attr(n, "role") {
+		case "alert", "log", "status":
+			return true
+		}
+	}
+	return false
+}
+
+// hasControl reports whether the label wraps a form control.
+func hasControl(n *html.Node) bool {
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if ch.Type == html.ElementNode {
+			switch ch.Data {
+			case "input", "select", "textarea":
+				return true
+			}
+		}
+		if hasControl(ch) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *checker) report(n *html.Node, detail string) {
+	c.problems = append(c.problems, Problem{Element: describe(n), Detail: detail})
+}
+
+// describe identifies the element in problem reports.
+func describe(n *html.Node) string {
+	var b strings.Builder
+	b.WriteString("<" + n.Data)
+	for _, a := range []string{"id", "name", "type", "href", "class"} {
+		if v := attr(n, a); v != "" {
+			fmt.Fprintf(&b, " %s=%q", a, v)
+			break
+		}
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func has(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func text(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		b.WriteString(text(ch))
+	}
+	return b.String()
+}
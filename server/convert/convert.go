@@ -0,0 +1,239 @@
+/*
+Package convert converts documents between TOML and JSON on top of the tq TOML
+adapter.
+
+Datetimes are converted by a fixed rule. TOML offset datetimes become RFC 3339
+strings and local dates, times and datetimes become strings in their TOML
+notation. In the other direction, only strings holding a full RFC 3339
+datetime with a time zone offset become TOML offset datetimes; all the other
+strings, including those that merely look like local dates, stay strings.
+
+Not everything can be converted. JSON documents whose root is not an object
+have no TOML counterpart, TOML has no null, and JSON has no NaN or infinity.
+JSON integers out of the range of TOML integers become floats, losing
+precision, and arrays mixing types are not valid in TOML before version 1.0.
+Such values are reported as issues along with the tq query path leading to
+them.
+*/
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	gotoml "github.com/pelletier/go-toml/v2"
+
+	"github.com/mdm-code/tqweb/server/diff"
+	"github.com/mdm-code/tqweb/server/eval"
+)
+
+// Supported formats.
+const (
+	TOML = "toml"
+	JSON = "json"
+)
+
+var (
+	// ErrFormat is returned for unsupported conversions.
+	ErrFormat = errors.New("unsupported conversion")
+
+	// ErrTrailingData is returned for JSON inputs holding more than a single
+	// JSON value.
+	ErrTrailingData = errors.New("trailing data after the JSON document")
+)
+
+// Issue is a value that could not be converted.
+type Issue struct {
+	Path   string `json:"path"`
+	Detail string `json:"detail"`
+}
+
+// Result is the converted document along with the values that did not make
+// it through the conversion. The document is empty when the input could not
+// be converted at all.
+type Result struct {
+	Document string  `json:"document"`
+	Issues   []Issue `json:"issues"`
+}
+
+// Convert converts the input from one format to the other. The options only
+// apply to the TOML output.
+func Convert(from, to, input string, o eval.Options) (Result, error) {
+	switch {
+	case from == TOML && to == JSON:
+		return TOMLToJSON(input)
+	case from == JSON && to == TOML:
+		return JSONToTOML(input, o)
+	}
+	return Result{}, fmt.Errorf("%w: from %q to %q", ErrFormat, from, to)
+}
+
+// TOMLToJSON converts a TOML document to indented JSON.
+func TOMLToJSON(input string) (Result, error) {
+	doc, err := eval.Decode(input)
+	if err != nil {
+		return Result{}, err
+	}
+	var issues []Issue
+	data := fromTOML(".", doc.Data(), &issues)
+	out, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return Result{}, err
+	}
+	sortIssues(issues)
+	return Result{Document: string(out) + "\n", Issues: issues}, nil
+}
+
+func fromTOML(path string, v any, issues *[]Issue) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[k] = fromTOML(join(path, diff.Key(k)), e, issues)
+		}
+		return m
+	case []any:
+		a := make([]any, len(v))
+		for i, e := range v {
+			a[i] = fromTOML(join(path, diff.Index(i)), e, issues)
+		}
+		return a
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case gotoml.LocalDate, gotoml.LocalTime, gotoml.LocalDateTime:
+		return fmt.Sprint(v)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			*issues = append(*issues, Issue{Path: path, Detail: fmt.Sprintf("JSON has no %v, it is converted to null", v)})
+			return nil
+		}
+	}
+	return v
+}
+
+// JSONToTOML converts a JSON document to TOML encoded with the options.
+func JSONToTOML(input string, o eval.Options) (Result, error) {
+	d := json.NewDecoder(bytes.NewReader([]byte(input)))
+	d.UseNumber()
+	var data any
+	if err := d.Decode(&data); err != nil {
+		return Result{}, err
+	}
+	if _, err := d.Token(); !errors.Is(err, io.EOF) {
+		return Result{}, ErrTrailingData
+	}
+	var issues []Issue
+	root, ok := data.(map[string]any)
+	if !ok {
+		detail := "the root of a TOML document must be a table, not %s"
+		issues = append(issues, Issue{Path: ".", Detail: fmt.Sprintf(detail, kind(data))})
+		return Result{Issues: issues}, nil
+	}
+	adapter := o.Adapter()
+	out, err := adapter.Marshal(fromJSON(".", root, &issues))
+	if err != nil {
+		return Result{}, err
+	}
+	sortIssues(issues)
+	return Result{Document: string(out), Issues: issues}, nil
+}
+
+func fromJSON(path string, v any, issues *[]Issue) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			if e == nil {
+				*issues = append(*issues, Issue{Path: join(path, diff.Key(k)), Detail: "TOML has no null, the key is left out"})
+				continue
+			}
+			m[k] = fromJSON(join(path, diff.Key(k)), e, issues)
+		}
+		return m
+	case []any:
+		a := make([]any, 0, len(v))
+		for i, e := range v {
+			if e == nil {
+				*issues = append(*issues, Issue{Path: join(path, diff.Index(i)), Detail: "TOML has no null, the element is left out"})
+				continue
+			}
+			a = append(a, fromJSON(join(path, diff.Index(i)), e, issues))
+		}
+		for _, e := range a[min(1, len(a)):] {
+			if tomlKind(e) != tomlKind(a[0]) {
+				detail := "the array mixes %s and %s, which TOML before 1.0 does not allow"
+				*issues = append(*issues, Issue{Path: path, Detail: fmt.Sprintf(detail, tomlKind(a[0]), tomlKind(e))})
+				break
+			}
+		}
+		return a
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		if !strings.ContainsAny(string(v), ".eE") {
+			detail := "%s is out of the range of TOML integers, it is converted to the float %v"
+			*issues = append(*issues, Issue{Path: path, Detail: fmt.Sprintf(detail, v, f)})
+		}
+		return f
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t
+		}
+	}
+	return v
+}
+
+// tomlKind names the TOML type of the converted value.
+func tomlKind(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "tables"
+	case []any:
+		return "arrays"
+	case int64:
+		return "integers"
+	case float64:
+		return "floats"
+	case bool:
+		return "booleans"
+	case time.Time:
+		return "datetimes"
+	}
+	return "strings"
+}
+
+func kind(v any) string {
+	switch v.(type) {
+	case []any:
+		return "an array"
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	}
+	return "a number"
+}
+
+// sortIssues orders issues by path since maps are walked in random order.
+func sortIssues(issues []Issue) {
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Path < issues[j].Path })
+}
+
+// join appends the filter to the path leaving out the identity of the root.
+func join(path, filter string) string {
+	if path == "." {
+		return filter
+	}
+	return path + filter
+}
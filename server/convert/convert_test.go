@@ -0,0 +1,63 @@
+package convert_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mdm-code/tqweb/server/convert"
+	"github.com/mdm-code/tqweb/server/eval"
+)
+
+func TestJSONToTOML(t *testing.T) {
+	for _, tc := range []struct {
+		input    string
+		document string
+		issues   []convert.Issue
+	}{
+		{
+			input:    `{"a": 1, "b": [1, 2], "c": [[1], ["x"]]}`,
+			document: "a = 1\nb = [1, 2]\nc = [[1], ['x']]\n",
+		},
+		{
+			input:    `{"a": 99999999999999999999, "b": 1.5e3}`,
+			document: "a = 100000000000000000000.0\nb = 1500.0\n",
+			issues: []convert.Issue{{
+				Path:   `["a"]`,
+				Detail: "99999999999999999999 is out of the range of TOML integers, it is converted to the float 1e+20",
+			}},
+		},
+		{
+			input:    `{"a": [1, "x"], "b": [{"c": 1}, 2], "d": [1, 2.5]}`,
+			document: "a = [1, 'x']\nb = [{c = 1}, 2]\nd = [1, 2.5]\n",
+			issues: []convert.Issue{
+				{Path: `["a"]`, Detail: "the array mixes integers and strings, which TOML before 1.0 does not allow"},
+				{Path: `["b"]`, Detail: "the array mixes tables and integers, which TOML before 1.0 does not allow"},
+				{Path: `["d"]`, Detail: "the array mixes integers and floats, which TOML before 1.0 does not allow"},
+			},
+		},
+	} {
+		r, err := convert.JSONToTOML(tc.input, eval.Options{})
+		if err != nil {
+			t.Errorf("%s: %v", tc.input, err)
+			continue
+		}
+		if r.Document != tc.document {
+			t.Errorf("%s: document %q, want %q", tc.input, r.Document, tc.document)
+		}
+		if !reflect.DeepEqual(r.Issues, tc.issues) {
+			t.Errorf("%s: issues %v, want %v", tc.input, r.Issues, tc.issues)
+		}
+	}
+}
+
+func TestJSONToTOMLTrailingData(t *testing.T) {
+	for _, input := range []string{`{"a": 1} {"b": 2}`, `{"a": 1} }`, `{"a": 1} 2`} {
+		if _, err := convert.JSONToTOML(input, eval.Options{}); !errors.Is(err, convert.ErrTrailingData) {
+			t.Errorf("%s: %v, want ErrTrailingData", input, err)
+		}
+	}
+	if _, err := convert.JSONToTOML("{\"a\": 1}\n\t ", eval.Options{}); err != nil {
+		t.Errorf("trailing whitespace: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Package cookie signs and verifies the values tqweb stores in browser cookies so
+that the state kept on the client side cannot be tampered with.
+*/
+package cookie
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// SecretEnv is the environment variable holding the cookie signing key. A
+// random key is generated on startup when it is not set, which invalidates
+// all cookies issued before the restart.
+const SecretEnv = "TQWEB_SECRET"
+
+// ErrInvalidSignature is returned when a cookie value fails verification.
+var ErrInvalidSignature = errors.New("invalid cookie signature")
+
+// Signer signs cookie values with HMAC-SHA256.
+type Signer struct {
+	key []byte
+}
+
+// NewSigner returns a new signer using the provided key.
+func NewSigner(key []byte) Signer {
+	return Signer{key: key}
+}
+
+// Default is the signer used by the tqweb routes.
+var Default = NewSigner(defaultKey())
+
+func defaultKey() []byte {
+	if secret := os.Getenv(SecretEnv); secret != "" {
+		return []byte(secret)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// Sign returns the value with its signature appended.
+func (s Signer) Sign(value string) string {
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(value))
+	return encoded + "." + s.signature(encoded)
+}
+
+// Verify checks the signature of the signed value and returns the original
+// value.
+func (s Signer) Verify(signed string) (string, error) {
+	encoded, signature, ok := strings.Cut(signed, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.signature(encoded))) {
+		return "", ErrInvalidSignature
+	}
+	value, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+	return string(value), nil
+}
+
+func (s Signer) signature(encoded string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Read returns the verified value of the named cookie.
+func (s Signer) Read(c echo.Context, name string) (string, error) {
+	ck, err := c.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return s.Verify(ck.Value)
+}
+
+// Write sets the named cookie to the signed value.
+func (s Signer) Write(c echo.Context, name, value string, maxAge time.Duration) {
+	c.SetCookie(&http.Cookie{
+		Name:     name,
+		Value:    s.Sign(value),
+		Path:     "/",
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,77 @@
+package cookie_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/mdm-code/tqweb/server/cookie"
+)
+
+func TestSignVerify(t *testing.T) {
+	s := cookie.NewSigner([]byte("key"))
+	for _, value := range []string{"", "keys,spans", "ąę;=, \x00"} {
+		got, err := s.Verify(s.Sign(value))
+		if err != nil || got != value {
+			t.Errorf("Verify(Sign(%q)) = %q, %v", value, got, err)
+		}
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	s := cookie.NewSigner([]byte("key"))
+	signed := s.Sign("keys,spans")
+	payload, mac, _ := strings.Cut(signed, ".")
+	other := cookie.NewSigner([]byte("other key")).Sign("keys,spans")
+	forged, _, _ := strings.Cut(s.Sign("keys,spans,chaining"), ".")
+	flip := func(s string) string {
+		b := []byte(s)
+		if b[0] == 'A' {
+			b[0] = 'B'
+		} else {
+			b[0] = 'A'
+		}
+		return string(b)
+	}
+	for name, value := range map[string]string{
+		"payload":         flip(payload) + "." + mac,
+		"swapped payload": forged + "." + mac,
+		"mac":             payload + "." + flip(mac),
+		"truncated mac":   payload + "." + mac[:len(mac)-1],
+		"no mac":          payload + ".",
+		"no separator":    payload + mac,
+		"empty":           "",
+		"other key":       other,
+		"extra part":      signed + ".x",
+	} {
+		if got, err := s.Verify(value); !errors.Is(err, cookie.ErrInvalidSignature) {
+			t.Errorf("%s: Verify() = %q, %v, want ErrInvalidSignature", name, got, err)
+		}
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	s := cookie.NewSigner([]byte("key"))
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	s.Write(e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec), "progress", "keys", time.Hour)
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly || cookies[0].MaxAge != 3600 {
+		t.Fatalf("cookies %v", cookies)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookies[0])
+	if got, err := s.Read(e.NewContext(req, httptest.NewRecorder()), "progress"); err != nil || got != "keys" {
+		t.Errorf("Read() = %q, %v", got, err)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "progress", Value: "keys"})
+	if _, err := s.Read(e.NewContext(req, httptest.NewRecorder()), "progress"); !errors.Is(err, cookie.ErrInvalidSignature) {
+		t.Errorf("Read() of an unsigned cookie = %v, want ErrInvalidSignature", err)
+	}
+}
@@ -0,0 +1,177 @@
+/*
+Package diff compares two decoded TOML documents structurally. Differences are
+reported as a list of additions, removals and changes, each located with the
+tq query that retrieves the value, for example ["servers"]["prod"]["ip"].
+*/
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mdm-code/tqweb/server/eval"
+)
+
+// ErrResults is returned for queries that do not yield exactly one result
+// to compare.
+var ErrResults = errors.New("query does not yield exactly one result")
+
+// Op is the kind of difference between two documents.
+type Op string
+
+const (
+	// Add marks a value present only in the right document.
+	Add Op = "add"
+
+	// Remove marks a value present only in the left document.
+	Remove Op = "remove"
+
+	// Change marks a value that differs between the documents.
+	Change Op = "change"
+)
+
+// Difference is a single difference between two documents.
+type Difference struct {
+	Op   Op     `json:"op"`
+	Path string `json:"path"`
+	From any    `json:"from,omitempty"`
+	To   any    `json:"to,omitempty"`
+}
+
+// Compare reports the differences between the left and the right value with
+// paths relative to the given prefix. Table keys are visited in sorted order
+// and arrays are compared element by element.
+func Compare(prefix string, left, right any) []Difference {
+	var diffs []Difference
+	compare(prefix, left, right, &diffs)
+	return diffs
+}
+
+func compare(path string, left, right any, diffs *[]Difference) {
+	switch l := left.(type) {
+	case map[string]any:
+		r, ok := right.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(l)+len(r))
+		for k := range l {
+			keys = append(keys, k)
+		}
+		for k := range r {
+			if _, ok := l[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			p := path + Key(k)
+			lv, lok := l[k]
+			rv, rok := r[k]
+			switch {
+			case !rok:
+				*diffs = append(*diffs, Difference{Op: Remove, Path: p, From: lv})
+			case !lok:
+				*diffs = append(*diffs, Difference{Op: Add, Path: p, To: rv})
+			default:
+				compare(p, lv, rv, diffs)
+			}
+		}
+		return
+	case []any:
+		r, ok := right.([]any)
+		if !ok {
+			break
+		}
+		for i := 0; i < max(len(l), len(r)); i++ {
+			p := path + Index(i)
+			switch {
+			case i >= len(r):
+				*diffs = append(*diffs, Difference{Op: Remove, Path: p, From: l[i]})
+			case i >= len(l):
+				*diffs = append(*diffs, Difference{Op: Add, Path: p, To: r[i]})
+			default:
+				compare(p, l[i], r[i], diffs)
+			}
+		}
+		return
+	}
+	if !reflect.DeepEqual(left, right) {
+		if path == "" {
+			path = "."
+		}
+		*diffs = append(*diffs, Difference{Op: Change, Path: path, From: left, To: right})
+	}
+}
+
+// KeyFilter returns the tq key filter selecting the key. tq takes the string
+// between the quotes as it is and trims all the quotes around it, so the key
+// is quoted with the kind of quotes it does not contain, and keys that cannot
+// be written that way are reported as such.
+func KeyFilter(k string) (string, bool) {
+	switch {
+	case strings.ContainsAny(k, "\n\r"), strings.Trim(k, `'"`) != k:
+		return "", false
+	case !strings.Contains(k, `"`):
+		return `["` + k + `"]`, true
+	case !strings.Contains(k, "'"):
+		return `['` + k + `']`, true
+	}
+	return "", false
+}
+
+// Key returns the tq key filter selecting the key of a table. Keys tq cannot
+// select are quoted as Go strings instead, so the path still names them.
+func Key(k string) string {
+	if f, ok := KeyFilter(k); ok {
+		return f
+	}
+	return "[" + strconv.Quote(k) + "]"
+}
+
+// Index returns the tq index filter selecting the element of an array.
+func Index(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
+
+// Documents decodes both TOML inputs and compares them. When the query is
+// not empty, it is applied to both documents first and only its results are
+// compared, so the paths of the differences stay queries tq can run. A query
+// has to yield exactly one result for each document.
+func Documents(left, right, query string) ([]Difference, error) {
+	l, err := side(left, query)
+	if err != nil {
+		return nil, err
+	}
+	r, err := side(right, query)
+	if err != nil {
+		return nil, err
+	}
+	root := "."
+	if strings.TrimSpace(query) != "" {
+		root = strings.TrimSpace(query)
+	}
+	return Compare(strings.TrimPrefix(root, "."), l, r), nil
+}
+
+func side(input, query string) (any, error) {
+	doc, err := eval.Decode(input)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(query) == "" {
+		return doc.Data(), nil
+	}
+	values, err := doc.Values(query, eval.Options{})
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 1 {
+		return nil, fmt.Errorf("%w: %d results", ErrResults, len(values))
+	}
+	return values[0], nil
+}
@@ -0,0 +1,143 @@
+package diff_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mdm-code/tqweb/server/diff"
+	"github.com/mdm-code/tqweb/server/eval"
+)
+
+func TestDocuments(t *testing.T) {
+	tests := []struct {
+		name, left, right, query string
+		want                     []diff.Difference
+	}{
+		{
+			name:  "equal",
+			left:  "a = 1\n[t]\nb = [1, 2]\n",
+			right: "t = {b = [1, 2]}\na = 1\n",
+		},
+		{
+			name:  "add, remove and change",
+			left:  "a = 1\nb = \"x\"\n[t]\nc = true\n",
+			right: "b = \"y\"\nd = 2\n[t]\nc = false\n",
+			want: []diff.Difference{
+				{Op: diff.Remove, Path: `["a"]`, From: int64(1)},
+				{Op: diff.Change, Path: `["b"]`, From: "x", To: "y"},
+				{Op: diff.Add, Path: `["d"]`, To: int64(2)},
+				{Op: diff.Change, Path: `["t"]["c"]`, From: true, To: false},
+			},
+		},
+		{
+			name:  "change of type",
+			left:  "[a]\nb = 1\n",
+			right: "a = [1]\n",
+			want:  []diff.Difference{{Op: diff.Change, Path: `["a"]`, From: map[string]any{"b": int64(1)}, To: []any{int64(1)}}},
+		},
+		{
+			name:  "arrays",
+			left:  "a = [1, 2, 3]\nb = [1]\n[[t]]\nn = 1\n",
+			right: "a = [1, 5]\nb = [1, 2]\n[[t]]\nn = 2\n",
+			want: []diff.Difference{
+				{Op: diff.Change, Path: `["a"][1]`, From: int64(2), To: int64(5)},
+				{Op: diff.Remove, Path: `["a"][2]`, From: int64(3)},
+				{Op: diff.Add, Path: `["b"][1]`, To: int64(2)},
+				{Op: diff.Change, Path: `["t"][0]["n"]`, From: int64(1), To: int64(2)},
+			},
+		},
+		{
+			name:  "query",
+			left:  "[servers.prod]\nip = \"10.0.0.1\"\n[servers.dev]\nip = \"10.0.0.9\"\n",
+			right: "[servers.prod]\nip = \"10.0.0.2\"\n",
+			query: `["servers"]["prod"]`,
+			want:  []diff.Difference{{Op: diff.Change, Path: `["servers"]["prod"]["ip"]`, From: "10.0.0.1", To: "10.0.0.2"}},
+		},
+		{
+			name:  "change of the query result",
+			left:  "a = 1\n",
+			right: "a = 2\n",
+			query: `.["a"]`,
+			want:  []diff.Difference{{Op: diff.Change, Path: `["a"]`, From: int64(1), To: int64(2)}},
+		},
+		{
+			name:  "change of the root",
+			left:  "a = 1\n",
+			right: "a = 2\n",
+			query: `.`,
+			want:  []diff.Difference{{Op: diff.Change, Path: `["a"]`, From: int64(1), To: int64(2)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have, err := diff.Documents(tt.left, tt.right, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(have, tt.want) {
+				t.Errorf("have %v\nwant %v", have, tt.want)
+			}
+		})
+	}
+}
+
+func TestDocumentsRejectsQueriesWithManyResults(t *testing.T) {
+	for _, query := range []string{`["servers"][]`, `["servers"][]["ip"]`, `["a"][]`} {
+		_, err := diff.Documents("a = []\n[[servers]]\nip = 1\n[[servers]]\nip = 2\n", "a = []\n", query)
+		if !errors.Is(err, diff.ErrResults) {
+			t.Errorf("%s: %v, want ErrResults", query, err)
+		}
+	}
+}
+
+// TestPathsRetrieveValues runs the path of every difference as a tq query
+// against the right document and checks it yields the new value.
+func TestPathsRetrieveValues(t *testing.T) {
+	left := "[t]\n"
+	right := "[t]\n\"a\\\"b\" = 1\n'c\\d' = 2\n\"x.y\" = 3\n\"with space\" = 4\n\"it's\" = [5]\n"
+	diffs, err := diff.Documents(left, right, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 5 {
+		t.Fatalf("differences %v", diffs)
+	}
+	doc, err := eval.Decode(right)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range diffs {
+		values, err := doc.Values(d.Path, eval.Options{})
+		if err != nil {
+			t.Errorf("%s: %v", d.Path, err)
+			continue
+		}
+		if len(values) != 1 || !reflect.DeepEqual(values[0], d.To) {
+			t.Errorf("%s yields %v, want %v", d.Path, values, d.To)
+		}
+	}
+}
+
+func TestKeyFilter(t *testing.T) {
+	tests := []struct {
+		key, want string
+		ok        bool
+	}{
+		{"a", `["a"]`, true},
+		{`a\b`, `["a\b"]`, true},
+		{`a"b`, `['a"b']`, true},
+		{"a'b", `["a'b"]`, true},
+		{`a'"b`, "", false},
+		{`"quoted"`, "", false},
+		{"a\nb", "", false},
+	}
+	for _, tt := range tests {
+		if f, ok := diff.KeyFilter(tt.key); f != tt.want || ok != tt.ok {
+			t.Errorf("KeyFilter(%q) = %s, %v, want %s, %v", tt.key, f, ok, tt.want, tt.ok)
+		}
+	}
+	if k := diff.Key(`a'"b`); k != `["a'\"b"]` {
+		t.Errorf("Key of a key tq cannot select = %s", k)
+	}
+}
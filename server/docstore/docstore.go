@@ -0,0 +1,142 @@
+/*
+Package docstore keeps decoded TOML documents in memory so that clients can
+upload a large input once and query it repeatedly by its ID. Documents expire
+after a fixed time to live, and the least recently used documents are evicted
+once the estimated memory taken by all of them exceeds a global budget.
+*/
+package docstore
+
+import (
+	"container/list"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mdm-code/tqweb/server/eval"
+)
+
+const (
+	// DefaultMaxBytes is the default memory budget of all stored documents.
+	DefaultMaxBytes = 256 << 20
+
+	// DefaultTTL is the default time documents are stored for.
+	DefaultTTL = 30 * time.Minute
+)
+
+var (
+	// ErrNotFound is returned for unknown and expired document IDs.
+	ErrNotFound = errors.New("document not found")
+
+	// ErrTooLarge is returned for documents larger than the whole budget.
+	ErrTooLarge = errors.New("document exceeds the memory budget")
+)
+
+// Info describes a stored document.
+type Info struct {
+	ID        string    `json:"id"`
+	Size      int       `json:"size"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type entry struct {
+	info Info
+	doc  eval.Document
+}
+
+// Store holds decoded documents. It is safe for concurrent use.
+type Store struct {
+	mu       sync.Mutex
+	maxBytes int
+	ttl      time.Duration
+	bytes    int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+// New returns a store holding at most maxBytes of documents for ttl each.
+func New(maxBytes int, ttl time.Duration) *Store {
+	return &Store{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Put stores the document and returns its description with a new random ID.
+func (s *Store) Put(doc eval.Document) (Info, error) {
+	size := Size(doc.Data())
+	if size > s.maxBytes {
+		return Info{}, ErrTooLarge
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return Info{}, err
+	}
+	info := Info{
+		ID:        hex.EncodeToString(id),
+		Size:      size,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire()
+	s.entries[info.ID] = s.order.PushFront(&entry{info: info, doc: doc})
+	s.bytes += size
+	for s.bytes > s.maxBytes {
+		s.remove(s.order.Back())
+	}
+	return info, nil
+}
+
+// Get returns the document stored under the ID.
+func (s *Store) Get(id string) (eval.Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire()
+	e, ok := s.entries[id]
+	if !ok {
+		return eval.Document{}, ErrNotFound
+	}
+	s.order.MoveToFront(e)
+	return e.Value.(*entry).doc, nil
+}
+
+// Delete removes the document stored under the ID.
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire()
+	e, ok := s.entries[id]
+	if !ok {
+		return ErrNotFound
+	}
+	s.remove(e)
+	return nil
+}
+
+// Bytes returns the estimated memory taken by the stored documents.
+func (s *Store) Bytes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bytes
+}
+
+// expire removes the expired documents. It must be called with the lock
+// held.
+func (s *Store) expire() {
+	now := time.Now()
+	for id, e := range s.entries {
+		if now.After(e.Value.(*entry).info.ExpiresAt) {
+			s.remove(s.entries[id])
+		}
+	}
+}
+
+func (s *Store) remove(e *list.Element) {
+	en := s.order.Remove(e).(*entry)
+	delete(s.entries, en.info.ID)
+	s.bytes -= en.info.Size
+}
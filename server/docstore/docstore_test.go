@@ -0,0 +1,125 @@
+package docstore_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mdm-code/tqweb/server/docstore"
+	"github.com/mdm-code/tqweb/server/eval"
+)
+
+func decode(t *testing.T, input string) eval.Document {
+	t.Helper()
+	doc, err := eval.Decode(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestPutGet(t *testing.T) {
+	s := docstore.New(docstore.DefaultMaxBytes, time.Minute)
+	doc := decode(t, "a = 1\n")
+	info, err := s.Put(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != docstore.Size(doc.Data()) || s.Bytes() != info.Size {
+		t.Errorf("size %d, %d bytes stored, want %d", info.Size, s.Bytes(), docstore.Size(doc.Data()))
+	}
+	if until := time.Until(info.ExpiresAt); until <= 0 || until > time.Minute {
+		t.Errorf("expires in %v", until)
+	}
+	got, err := s.Get(info.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := got.Run(`["a"]`, eval.Options{}); err != nil || out != "1\n" {
+		t.Errorf("Run() = %q, %v", out, err)
+	}
+	other, err := s.Put(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.ID == info.ID {
+		t.Error("documents share an ID")
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	s := docstore.New(docstore.DefaultMaxBytes, -time.Second)
+	info, err := s.Put(decode(t, "a = 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(info.ID); !errors.Is(err, docstore.ErrNotFound) {
+		t.Errorf("Get() of an expired document = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(info.ID); !errors.Is(err, docstore.ErrNotFound) {
+		t.Errorf("Delete() of an expired document = %v, want ErrNotFound", err)
+	}
+	if s.Bytes() != 0 {
+		t.Errorf("%d bytes kept for expired documents", s.Bytes())
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	docs := make([]eval.Document, 3)
+	for i := range docs {
+		docs[i] = decode(t, fmt.Sprintf("key = %d\n", i))
+	}
+	size := docstore.Size(docs[0].Data())
+	s := docstore.New(2*size, time.Minute)
+	var ids []string
+	for i, doc := range docs {
+		info, err := s.Put(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, info.ID)
+		if i == 1 {
+			// Using the first document makes the second one the least
+			// recently used.
+			if _, err := s.Get(ids[0]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if _, err := s.Get(ids[1]); !errors.Is(err, docstore.ErrNotFound) {
+		t.Errorf("the least recently used document is kept: %v", err)
+	}
+	for _, id := range []string{ids[0], ids[2]} {
+		if _, err := s.Get(id); err != nil {
+			t.Errorf("Get(%s) = %v", id, err)
+		}
+	}
+	if s.Bytes() != 2*size {
+		t.Errorf("%d bytes stored, want %d", s.Bytes(), 2*size)
+	}
+	large := decode(t, "a = 1\nb = 2\nc = 3\nd = 4\ne = 5\n")
+	if _, err := s.Put(large); !errors.Is(err, docstore.ErrTooLarge) {
+		t.Errorf("Put() of a document over the budget = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	s := docstore.New(docstore.DefaultMaxBytes, time.Minute)
+	info, err := s.Put(decode(t, "a = 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(info.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(info.ID); !errors.Is(err, docstore.ErrNotFound) {
+		t.Errorf("Get() after Delete() = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(info.ID); !errors.Is(err, docstore.ErrNotFound) {
+		t.Errorf("Delete() twice = %v, want ErrNotFound", err)
+	}
+	if s.Bytes() != 0 {
+		t.Errorf("%d bytes kept after Delete()", s.Bytes())
+	}
+}
@@ -0,0 +1,40 @@
+package docstore
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// Approximate overheads of the decoded values on a 64-bit platform.
+const (
+	interfaceSize = int(unsafe.Sizeof(any(nil)))
+	stringSize    = int(unsafe.Sizeof(""))
+	sliceSize     = int(unsafe.Sizeof([]any(nil)))
+	mapEntrySize  = stringSize + interfaceSize + 8
+	mapSize       = 48
+)
+
+// Size estimates the memory taken by a decoded TOML document. It counts the
+// interface holding every value, the backing arrays of strings, arrays and
+// tables, and a rough overhead of the maps.
+func Size(v any) int {
+	n := interfaceSize
+	switch v := v.(type) {
+	case map[string]any:
+		n += mapSize
+		for k, e := range v {
+			n += mapEntrySize + len(k) + Size(e) - interfaceSize
+		}
+	case []any:
+		n += sliceSize
+		for _, e := range v {
+			n += Size(e)
+		}
+	case string:
+		n += stringSize + len(v)
+	case nil:
+	default:
+		n += int(reflect.TypeOf(v).Size())
+	}
+	return n
+}
@@ -0,0 +1,79 @@
+package eval
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/mdm-code/tq/toml"
+)
+
+// The tq interpreter itself cannot be interrupted, so cancellation is checked
+// wherever tq hands control back to tqweb: while the input is being read,
+// before it is decoded and before every result is encoded.
+
+// contextReader fails reads once the context is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// contextCodec fails decoding and encoding once the context is done.
+type contextCodec struct {
+	ctx context.Context
+	toml.DecodeEncoder
+}
+
+func (c contextCodec) Decode(r io.Reader, v any) error {
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	return c.DecodeEncoder.Decode(contextReader{c.ctx, r}, v)
+}
+
+func (c contextCodec) Encode(v any) ([]byte, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.DecodeEncoder.Encode(v)
+}
+
+// DecodeContext decodes the TOML input into a document unless the context is
+// done first.
+func DecodeContext(ctx context.Context, input string) (Document, error) {
+	var data any
+	adapter := toml.NewAdapter(contextCodec{ctx, toml.NewGoTOML(Options{}.Conf())})
+	if err := adapter.Unmarshal(contextReader{ctx, strings.NewReader(input)}, &data); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return Document{}, ctxErr
+		}
+		return Document{}, err
+	}
+	return Document{data: data}, nil
+}
+
+// RunQueriesContext runs the queries like RunQueries but stops as soon as the
+// context is done and returns the context error.
+func (d Document) RunQueriesContext(ctx context.Context, queries []Query, o Options) ([]Result, error) {
+	results := make([]Result, 0, len(queries))
+	for _, q := range queries {
+		r := Result{Name: q.Name, Query: q.Query}
+		output, err := d.run(ctx, q.Query, o)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if err != nil {
+			r.Error = err.Error()
+		}
+		r.Output = output
+		results = append(results, r)
+	}
+	return results, nil
+}
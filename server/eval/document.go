@@ -0,0 +1,95 @@
+package eval
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/mdm-code/tq"
+	"github.com/mdm-code/tq/toml"
+)
+
+// ErrDecodeTarget is returned when tq asks for the decoded document to be
+// stored in a value other than an empty interface.
+var ErrDecodeTarget = errors.New("unsupported decode target")
+
+// Document is a TOML input decoded once so that any number of queries can run
+// against it without decoding the input again.
+type Document struct {
+	data any
+}
+
+// Decode decodes the TOML input into a document.
+func Decode(input string) (Document, error) {
+	return DecodeContext(context.Background(), input)
+}
+
+// Data returns the decoded document. The returned value is shared by all the
+// queries run against the document and must not be modified.
+func (d Document) Data() any {
+	return d.data
+}
+
+// decoded hands the already decoded document over to tq in place of decoding
+// the input reader.
+type decoded struct {
+	data any
+}
+
+func (d decoded) Decode(_ io.Reader, v any) error {
+	p, ok := v.(*any)
+	if !ok {
+		return ErrDecodeTarget
+	}
+	*p = d.data
+	return nil
+}
+
+// Run runs the tq query against the document and returns the output.
+func (d Document) Run(query string, o Options) (string, error) {
+	return d.run(context.Background(), query, o)
+}
+
+func (d Document) run(ctx context.Context, query string, o Options) (string, error) {
+	var output bytes.Buffer
+	c := contextCodec{ctx, codec{decoded{d.data}, toml.NewGoTOML(o.Conf())}}
+	t := tq.New(toml.NewAdapter(c))
+	if err := t.Run(strings.NewReader(""), &output, query); err != nil {
+		return "", err
+	}
+	return output.String(), nil
+}
+
+// Values runs the tq query against the document and returns the results as
+// values rather than encoded TOML.
+func (d Document) Values(query string, o Options) ([]any, error) {
+	c := &capture{Encoder: toml.NewGoTOML(o.Conf())}
+	t := tq.New(toml.NewAdapter(codec{decoded{d.data}, c}))
+	if err := t.Run(strings.NewReader(""), io.Discard, query); err != nil {
+		return nil, err
+	}
+	return c.values, nil
+}
+
+// Query is a tq query with a name given to it by the user.
+type Query struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+}
+
+// Result is the outcome of running a single named query.
+type Result struct {
+	Name   string `json:"name"`
+	Query  string `json:"query"`
+	Output string `json:"output"`
+	Error  string `json:"error,omitempty"`
+}
+
+// RunQueries runs every query against the document independently of one
+// another, so a failing query does not affect the results of the others.
+func (d Document) RunQueries(queries []Query, o Options) []Result {
+	results, _ := d.RunQueriesContext(context.Background(), queries, o)
+	return results
+}
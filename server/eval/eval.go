@@ -0,0 +1,114 @@
+/*
+Package eval runs tq queries against TOML input on behalf of the tqweb
+handlers. It translates the output flags exposed in the web interface into the
+configuration understood by the tq TOML adapter.
+*/
+package eval
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/mdm-code/tq"
+	"github.com/mdm-code/tq/toml"
+)
+
+// DefaultIndentSymbol is the indentation string used by the tq command-line
+// program when none is provided.
+const DefaultIndentSymbol = "  "
+
+// Options mirrors the output flags of the tq command-line program.
+type Options struct {
+	TablesInline    bool   `toml:"tables-inline" json:"tablesInline"`
+	ArraysMultiline bool   `toml:"arrays-multiline" json:"arraysMultiline"`
+	IndentSymbol    string `toml:"indent-symbol" json:"indentSymbol"`
+	IndentTables    bool   `toml:"indent-tables" json:"indentTables"`
+}
+
+// Conf converts the options into the tq TOML encoder configuration.
+func (o Options) Conf() toml.GoTOMLConf {
+	var conf toml.GoTOMLConf
+	conf.Encoder.TablesInline = o.TablesInline
+	conf.Encoder.ArraysMultiline = o.ArraysMultiline
+	conf.Encoder.IndentSymbol = o.IndentSymbol
+	if conf.Encoder.IndentSymbol == "" {
+		conf.Encoder.IndentSymbol = DefaultIndentSymbol
+	}
+	conf.Encoder.IndentTables = o.IndentTables
+	return conf
+}
+
+// Adapter returns the tq TOML adapter configured with the options.
+func (o Options) Adapter() toml.Adapter {
+	return toml.NewAdapter(toml.NewGoTOML(o.Conf()))
+}
+
+// Run runs the tq query against the TOML input and returns the output.
+func Run(query, input string, o Options) (string, error) {
+	var output bytes.Buffer
+	t := tq.New(o.Adapter())
+	if err := t.Run(strings.NewReader(input), &output, query); err != nil {
+		return "", err
+	}
+	return output.String(), nil
+}
+
+// capture records the values tq writes out on top of encoding them.
+type capture struct {
+	toml.Encoder
+	values []any
+}
+
+func (c *capture) Encode(v any) ([]byte, error) {
+	bytes, err := c.Encoder.Encode(v)
+	if err == nil && len(bytes) > 0 {
+		c.values = append(c.values, v)
+	}
+	return bytes, err
+}
+
+// codec puts together the decoder and the encoder used by the tq adapter.
+type codec struct {
+	toml.Decoder
+	toml.Encoder
+}
+
+// Values runs the tq query against the TOML input and returns the results as
+// decoded values rather than encoded TOML.
+func Values(query, input string, o Options) ([]any, error) {
+	goTOML := toml.NewGoTOML(o.Conf())
+	c := &capture{Encoder: goTOML}
+	t := tq.New(toml.NewAdapter(codec{goTOML, c}))
+	if err := t.Run(strings.NewReader(input), io.Discard, query); err != nil {
+		return nil, err
+	}
+	return c.values, nil
+}
+
+// Equal reports whether both results hold the same values. Iterating over a
+// table yields values in no particular order, so the order of the values is
+// not taken into account.
+func Equal(a, b []any) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	matched := make([]bool, len(b))
+outer:
+	for _, x := range a {
+		for i, y := range b {
+			if !matched[i] && reflect.DeepEqual(x, y) {
+				matched[i] = true
+				continue outer
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Validate verifies if the tq query is valid without running it.
+func Validate(query string) error {
+	return tq.New(Options{}.Adapter()).Validate(query)
+}
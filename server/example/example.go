@@ -0,0 +1,95 @@
+/*
+Package example provides the curated gallery of tq queries shown by tqweb. Each
+example is stored as a TOML manifest embedded in the binary and records the
+query, the input, the output flags, and the output tq is expected to produce.
+*/
+package example
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/mdm-code/tq/toml"
+	"github.com/mdm-code/tqweb/server/eval"
+)
+
+const manifestDir = "manifest"
+
+//go:embed manifest/*.toml
+var manifests embed.FS
+
+// ErrNotFound is returned when no example matches the requested ID.
+var ErrNotFound = errors.New("example not found")
+
+// Example is a single curated scenario from the gallery.
+type Example struct {
+	ID          string       `toml:"-"`
+	Title       string       `toml:"title"`
+	Description string       `toml:"description"`
+	Query       string       `toml:"query"`
+	Input       string       `toml:"input"`
+	Flags       eval.Options `toml:"flags"`
+	Output      string       `toml:"output"`
+}
+
+// Run runs the example query against the example input.
+func (e Example) Run() (string, error) {
+	return eval.Run(e.Query, e.Input, e.Flags)
+}
+
+var (
+	loadOnce sync.Once
+	examples []Example
+	loadErr  error
+)
+
+// All returns all examples from the gallery ordered by their ID.
+func All() ([]Example, error) {
+	loadOnce.Do(func() {
+		examples, loadErr = load(manifests)
+	})
+	return examples, loadErr
+}
+
+// Get returns the example with the given ID.
+func Get(id string) (Example, error) {
+	all, err := All()
+	if err != nil {
+		return Example{}, err
+	}
+	for _, e := range all {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return Example{}, ErrNotFound
+}
+
+func load(fsys fs.FS) ([]Example, error) {
+	entries, err := fs.ReadDir(fsys, manifestDir)
+	if err != nil {
+		return nil, err
+	}
+	decoder := toml.NewGoTOML(toml.GoTOMLConf{})
+	result := make([]Example, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		data, err := fs.ReadFile(fsys, path.Join(manifestDir, name))
+		if err != nil {
+			return nil, err
+		}
+		var e Example
+		if err := decoder.Decode(bytes.NewReader(data), &e); err != nil {
+			return nil, fmt.Errorf("example %s: %w", name, err)
+		}
+		e.ID = strings.TrimSuffix(name, path.Ext(name))
+		result = append(result, e)
+	}
+	return result, nil
+}
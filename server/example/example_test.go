@@ -0,0 +1,51 @@
+package example
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// lines returns the sorted lines of the output. Iterating over a table
+// yields its values in no particular order, so the outputs are compared
+// regardless of the order of their lines.
+func lines(output string) []string {
+	l := strings.Split(output, "\n")
+	slices.Sort(l)
+	return l
+}
+
+func TestAll(t *testing.T) {
+	all, err := All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) == 0 {
+		t.Fatal("no examples")
+	}
+	for _, e := range all {
+		t.Run(e.ID, func(t *testing.T) {
+			have, err := e.Run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(lines(have), lines(e.Output)) {
+				t.Errorf("have %q, want %q", have, e.Output)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	all, err := All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := Get(all[0].ID)
+	if err != nil || e.ID != all[0].ID {
+		t.Errorf("Get(%q) = %v, %v", all[0].ID, e.ID, err)
+	}
+	if _, err := Get("missing"); err != ErrNotFound {
+		t.Errorf("Get(missing) error = %v, want %v", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Package filter is the registry of the filters supported by tq. It documents the
+syntax, the semantics and the edge cases of each filter together with runnable
+examples. The registry backs both the reference page and the tooltips shown in
+the playground, and the output of every example is computed with tq when the
+program starts, so the documentation always reflects the behaviour of the tq
+version tqweb is built with.
+*/
+package filter
+
+import (
+	"github.com/mdm-code/tqweb/server/eval"
+)
+
+// Filter documents a single tq filter.
+type Filter struct {
+	Name      string
+	Syntax    string
+	Semantics string
+	EdgeCases []Example
+	Examples  []Example
+}
+
+// Example is a runnable mini-example of a filter.
+type Example struct {
+	Note   string
+	Query  string
+	Input  string
+	Output string
+	Err    string
+}
+
+// run evaluates the example and records its output or its error.
+func (e Example) run() Example {
+	output, err := eval.Run(e.Query, e.Input, eval.Options{})
+	e.Output = output
+	if err != nil {
+		e.Err = err.Error()
+	}
+	return e
+}
+
+// Tooltip returns the short description of the filter shown in the
+// playground.
+func (f Filter) Tooltip() string {
+	return f.Syntax + " - " + f.Semantics
+}
+
+var registry = evaluate([]Filter{
+	{
+		Name:      "identity",
+		Syntax:    ".",
+		Semantics: "Passes the input through unchanged.",
+		Examples: []Example{
+			{
+				Query: ".",
+				Input: "name = \"tqweb\"\nstars = 5\n",
+			},
+		},
+		EdgeCases: []Example{
+			{
+				Note:  "The identity filter can be placed anywhere in the query without changing the result.",
+				Query: `.["name"].`,
+				Input: "name = \"tqweb\"\n",
+			},
+		},
+	},
+	{
+		Name:      "key",
+		Syntax:    `["string"]`,
+		Semantics: "Selects the value stored under the key of a table.",
+		Examples: []Example{
+			{
+				Query: `["owner"]["name"]`,
+				Input: "[owner]\nname = \"Tom\"\n",
+			},
+		},
+		EdgeCases: []Example{
+			{
+				Note:  "A missing key yields nothing.",
+				Query: `["missing"]`,
+				Input: "name = \"tqweb\"\n",
+			},
+			{
+				Note:  "Querying a value other than a table with a key is a type error.",
+				Query: `["name"]["first"]`,
+				Input: "name = \"tqweb\"\n",
+			},
+		},
+	},
+	{
+		Name:      "index",
+		Syntax:    "[0]",
+		Semantics: "Selects the element of an array at the zero-based index.",
+		Examples: []Example{
+			{
+				Query: `["ports"][1]`,
+				Input: "ports = [8000, 8001, 8002]\n",
+			},
+		},
+		EdgeCases: []Example{
+			{
+				Note:  "An index out of range yields nothing.",
+				Query: `["ports"][7]`,
+				Input: "ports = [8000, 8001, 8002]\n",
+			},
+			{
+				Note:  "Indexing a table is a type error.",
+				Query: `[0]`,
+				Input: "port = 8000\n",
+			},
+		},
+	},
+	{
+		Name:      "iterator",
+		Syntax:    "[]",
+		Semantics: "Yields every element of an array or every value of a table.",
+		Examples: []Example{
+			{
+				Query: `["ports"][]`,
+				Input: "ports = [8000, 8001, 8002]\n",
+			},
+		},
+		EdgeCases: []Example{
+			{
+				Note:  "Iterating over a table yields its values in no particular order.",
+				Query: `["servers"][]["ip"]`,
+				Input: "[servers.prod]\nip = \"10.0.0.1\"\n\n[servers.staging]\nip = \"10.0.0.2\"\n",
+			},
+			{
+				Note:  "Iterating over a scalar value is a type error.",
+				Query: `["port"][]`,
+				Input: "port = 8000\n",
+			},
+		},
+	},
+	{
+		Name:      "span",
+		Syntax:    "[:]",
+		Semantics: "Selects the part of an array between the start and the end index; either bound can be left out.",
+		Examples: []Example{
+			{
+				Query: `["ports"][1:]`,
+				Input: "ports = [8000, 8001, 8002]\n",
+			},
+		},
+		EdgeCases: []Example{
+			{
+				Note:  "The end bound past the end of the array is cut down to its length.",
+				Query: `["ports"][1:10]`,
+				Input: "ports = [8000, 8001, 8002]\n",
+			},
+			{
+				Note:  "A span starting out of range yields nothing.",
+				Query: `["ports"][5:]`,
+				Input: "ports = [8000, 8001, 8002]\n",
+			},
+			{
+				Note:  "Spanning a table is a type error.",
+				Query: `[0:1]`,
+				Input: "port = 8000\n",
+			},
+		},
+	},
+})
+
+func evaluate(filters []Filter) []Filter {
+	for i, f := range filters {
+		for j, e := range f.Examples {
+			filters[i].Examples[j] = e.run()
+		}
+		for j, e := range f.EdgeCases {
+			filters[i].EdgeCases[j] = e.run()
+		}
+	}
+	return filters
+}
+
+// All returns every filter in the registry.
+func All() []Filter {
+	return registry
+}
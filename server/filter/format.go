@@ -0,0 +1,40 @@
+package filter
+
+import (
+	"strings"
+
+	"github.com/mdm-code/tqweb/server/eval"
+)
+
+// Format rewrites a valid tq query in its canonical form: white space
+// between the filters is dropped and keys are quoted with double quotes
+// unless they contain one.
+func Format(query string) (string, error) {
+	if err := eval.Validate(query); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	var quote rune
+	start := 0
+	for i, r := range query {
+		switch {
+		case quote != 0:
+			if r == quote {
+				key := query[start+1 : i]
+				if quote == '\'' && !strings.ContainsRune(key, '"') {
+					quote = '"'
+				}
+				b.WriteRune(quote)
+				b.WriteString(key)
+				b.WriteRune(quote)
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote, start = r, i
+		case r == ' ' || r == '\t':
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), nil
+}
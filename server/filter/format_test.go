@@ -0,0 +1,52 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/mdm-code/tqweb/server/filter"
+)
+
+func TestFormat(t *testing.T) {
+	const input = "ports = [80, 443]\n\n[servers.\"a b\"]\nip = \"10.0.0.1\"\n\n[servers.'say \"hi\"']\nip = \"10.0.0.2\"\n\n[servers.\"it's\"]\nip = \"10.0.0.3\"\n"
+	tests := []struct {
+		query, want string
+	}{
+		{".", "."},
+		{" . ", "."},
+		{`["servers"]`, `["servers"]`},
+		{` . [ "ports" ] [ ] `, `.["ports"][]`},
+		{` [ "servers" ] [ "a b" ] [ "ip" ] `, `["servers"]["a b"]["ip"]`},
+		{"\t['servers']\t['a b']", `["servers"]["a b"]`},
+		{`["servers"]['say "hi"']`, `["servers"]['say "hi"']`},
+		{`["servers"]["it's"]`, `["servers"]["it's"]`},
+		{`["ports"][ 0 : 1 ]`, `["ports"][0:1]`},
+		{`[ : ]`, `[:]`},
+	}
+	for _, tt := range tests {
+		have, err := filter.Format(tt.query)
+		if err != nil {
+			t.Errorf("Format(%q) = %v", tt.query, err)
+			continue
+		}
+		if have != tt.want {
+			t.Errorf("Format(%q) = %q, want %q", tt.query, have, tt.want)
+		}
+		if again, err := filter.Format(have); err != nil || again != have {
+			t.Errorf("Format(%q) = %q, %v, want it unchanged", have, again, err)
+		}
+		before, beforeErr := eval.Run(tt.query, input, eval.Options{})
+		after, afterErr := eval.Run(have, input, eval.Options{})
+		if before != after || (beforeErr == nil) != (afterErr == nil) {
+			t.Errorf("%q yields %q, %v but %q yields %q, %v", tt.query, before, beforeErr, have, after, afterErr)
+		}
+	}
+}
+
+func TestFormatInvalid(t *testing.T) {
+	for _, query := range []string{`["servers"`, `["servers`, `$`, `]`} {
+		if q, err := filter.Format(query); err == nil {
+			t.Errorf("Format(%q) = %q, want an error", query, q)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+/*
+Package format normalizes TOML documents by decoding them and encoding them
+back with the tq TOML encoder. Decoding keeps nothing but the data, so the
+package also compares the normalized document with the source and reports the
+features of the source that do not survive the round trip: comments, the
+order of keys and the way literals are written.
+*/
+package format
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mdm-code/tqweb/server/diff"
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+// Features lost in the round trip.
+const (
+	Comments          = "comments"
+	KeyOrder          = "key order"
+	LiteralFormatting = "literal formatting"
+)
+
+// Loss is a feature of the source document lost in the round trip.
+type Loss struct {
+	Feature string `json:"feature"`
+	Path    string `json:"path,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Detail  string `json:"detail"`
+}
+
+// Result is the normalized document along with what was lost to get it.
+type Result struct {
+	Document string `json:"document"`
+	Losses   []Loss `json:"losses"`
+}
+
+// Format decodes the TOML input and encodes it back with the encoder options.
+func Format(input string, o eval.Options) (Result, error) {
+	doc, err := eval.Decode(input)
+	if err != nil {
+		return Result{}, err
+	}
+	adapter := o.Adapter()
+	output, err := adapter.Marshal(doc.Data())
+	if err != nil {
+		return Result{}, err
+	}
+	src, err := scan(input)
+	if err != nil {
+		return Result{}, err
+	}
+	dst, err := scan(string(output))
+	if err != nil {
+		return Result{}, err
+	}
+	losses := append([]Loss{}, src.comments...)
+	losses = append(losses, keyOrder(src, dst)...)
+	losses = append(losses, literals(src, dst)...)
+	return Result{Document: string(output), Losses: losses}, nil
+}
+
+// keyOrder reports the first key of the source that no longer comes in the
+// same order relative to the other keys.
+func keyOrder(src, dst *document) []Loss {
+	var before, after []string
+	for _, l := range src.leaves {
+		if _, ok := dst.raw[l.path]; ok {
+			before = append(before, l.path)
+		}
+	}
+	for _, l := range dst.leaves {
+		if _, ok := src.raw[l.path]; ok {
+			after = append(after, l.path)
+		}
+	}
+	for i := range min(len(before), len(after)) {
+		if before[i] != after[i] {
+			return []Loss{{
+				Feature: KeyOrder,
+				Path:    before[i],
+				Line:    src.line[before[i]],
+				Detail:  fmt.Sprintf("%s is moved after %s", before[i], after[i]),
+			}}
+		}
+	}
+	return nil
+}
+
+// literals reports the values written differently in the normalized
+// document.
+func literals(src, dst *document) []Loss {
+	var losses []Loss
+	for _, l := range src.leaves {
+		raw, ok := dst.raw[l.path]
+		if !ok || raw == l.raw {
+			continue
+		}
+		losses = append(losses, Loss{
+			Feature: LiteralFormatting,
+			Path:    l.path,
+			Line:    src.line[l.path],
+			Detail:  fmt.Sprintf("%s is written as %s", l.raw, raw),
+		})
+	}
+	return losses
+}
+
+type leaf struct {
+	path string
+	raw  string
+}
+
+// document lists the leaf values of a TOML document in the order they are
+// written along with their raw text and its comments.
+type document struct {
+	p        *unstable.Parser
+	leaves   []leaf
+	raw      map[string]string
+	line     map[string]int
+	arrays   map[string]int
+	comments []Loss
+}
+
+func scan(input string) (*document, error) {
+	d := &document{
+		p:      &unstable.Parser{KeepComments: true},
+		raw:    make(map[string]string),
+		line:   make(map[string]int),
+		arrays: make(map[string]int),
+	}
+	d.p.Reset([]byte(input))
+	var table string
+	for d.p.NextExpression() {
+		e := d.p.Expression()
+		switch e.Kind {
+		case unstable.Comment:
+			d.comment(e)
+		case unstable.Table, unstable.ArrayTable:
+			table = d.header(e)
+		case unstable.KeyValue:
+			d.keyValue(table, e)
+		}
+		if next := e.Next(); next != nil && next.Kind == unstable.Comment {
+			d.comment(next)
+		}
+	}
+	return d, d.p.Error()
+}
+
+// header resolves the path of a table header. Array tables already seen are
+// entered at their last element, and the header of an array table adds a new
+// element to it.
+func (d *document) header(e *unstable.Node) string {
+	var path string
+	it := e.Key()
+	for it.Next() {
+		path += diff.Key(string(it.Node().Data))
+		if e.Kind == unstable.ArrayTable && it.IsLast() {
+			d.arrays[path]++
+		}
+		if n := d.arrays[path]; n > 0 {
+			path += diff.Index(n - 1)
+		}
+	}
+	return path
+}
+
+func (d *document) keyValue(table string, e *unstable.Node) {
+	path := table
+	it := e.Key()
+	for it.Next() {
+		path += diff.Key(string(it.Node().Data))
+	}
+	d.value(path, e.Value())
+}
+
+func (d *document) value(path string, v *unstable.Node) {
+	switch v.Kind {
+	case unstable.Array:
+		var i int
+		it := v.Children()
+		for it.Next() {
+			if n := it.Node(); n.Kind == unstable.Comment {
+				d.comment(n)
+			} else {
+				d.value(path+diff.Index(i), n)
+				i++
+			}
+		}
+	case unstable.InlineTable:
+		it := v.Children()
+		for it.Next() {
+			d.keyValue(path, it.Node())
+		}
+	case unstable.String:
+		d.leaf(path, string(d.p.Raw(v.Raw)), v.Raw)
+	default:
+		d.leaf(path, string(v.Data), d.p.Range(v.Data))
+	}
+}
+
+func (d *document) leaf(path, raw string, r unstable.Range) {
+	d.leaves = append(d.leaves, leaf{path: path, raw: raw})
+	d.raw[path] = raw
+	d.line[path] = d.p.Shape(r).Start.Line
+}
+
+func (d *document) comment(n *unstable.Node) {
+	d.comments = append(d.comments, Loss{
+		Feature: Comments,
+		Line:    d.p.Shape(n.Raw).Start.Line,
+		Detail:  strings.TrimSpace(string(n.Data)),
+	})
+}
@@ -0,0 +1,117 @@
+package format_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/mdm-code/tqweb/server/format"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name, input, document string
+		losses                []format.Loss
+	}{
+		{
+			name:     "nothing lost",
+			input:    "x = 1\n\n[t]\na = 'y'\n",
+			document: "x = 1\n\n[t]\na = 'y'\n",
+			losses:   []format.Loss{},
+		},
+		{
+			name:     "comments",
+			input:    "# top\na = [\n  1, # one\n  2,\n] # trailing\n",
+			document: "a = [1, 2]\n",
+			losses: []format.Loss{
+				{Feature: format.Comments, Line: 1, Detail: "# top"},
+				{Feature: format.Comments, Line: 3, Detail: "# one"},
+				{Feature: format.Comments, Line: 5, Detail: "# trailing"},
+			},
+		},
+		{
+			name:     "key order",
+			input:    "b = 1\na = 2\n",
+			document: "a = 2\nb = 1\n",
+			losses:   []format.Loss{{Feature: format.KeyOrder, Path: `["b"]`, Line: 1, Detail: `["b"] is moved after ["a"]`}},
+		},
+		{
+			name:     "order of tables",
+			input:    "[t]\nb = 1\n\n[s]\na = 1\n",
+			document: "[s]\na = 1\n\n[t]\nb = 1\n",
+			losses:   []format.Loss{{Feature: format.KeyOrder, Path: `["t"]["b"]`, Line: 2, Detail: `["t"]["b"] is moved after ["s"]["a"]`}},
+		},
+		{
+			name:     "order of inline tables",
+			input:    "t = {b = 1, a = 2}\n",
+			document: "[t]\na = 2\nb = 1\n",
+			losses:   []format.Loss{{Feature: format.KeyOrder, Path: `["t"]["b"]`, Line: 1, Detail: `["t"]["b"] is moved after ["t"]["a"]`}},
+		},
+		{
+			name:     "integers",
+			input:    "a = 0x10\nb = 1_000\nc = 0o7\nd = 0b1\ne = +1\n",
+			document: "a = 16\nb = 1000\nc = 7\nd = 1\ne = 1\n",
+			losses: []format.Loss{
+				{Feature: format.LiteralFormatting, Path: `["a"]`, Line: 1, Detail: "0x10 is written as 16"},
+				{Feature: format.LiteralFormatting, Path: `["b"]`, Line: 2, Detail: "1_000 is written as 1000"},
+				{Feature: format.LiteralFormatting, Path: `["c"]`, Line: 3, Detail: "0o7 is written as 7"},
+				{Feature: format.LiteralFormatting, Path: `["d"]`, Line: 4, Detail: "0b1 is written as 1"},
+				{Feature: format.LiteralFormatting, Path: `["e"]`, Line: 5, Detail: "+1 is written as 1"},
+			},
+		},
+		{
+			name:     "floats",
+			input:    "a = 1e3\nb = 1.50\nc = inf\nd = -0.0\n",
+			document: "a = 1000.0\nb = 1.5\nc = inf\nd = -0.0\n",
+			losses: []format.Loss{
+				{Feature: format.LiteralFormatting, Path: `["a"]`, Line: 1, Detail: "1e3 is written as 1000.0"},
+				{Feature: format.LiteralFormatting, Path: `["b"]`, Line: 2, Detail: "1.50 is written as 1.5"},
+			},
+		},
+		{
+			name:     "strings",
+			input:    "a = 'literal'\nb = \"\"\"multi\"\"\"\nc = \"esc\\u0041\"\nd = \"basic\"\n",
+			document: "a = 'literal'\nb = 'multi'\nc = 'escA'\nd = 'basic'\n",
+			losses: []format.Loss{
+				{Feature: format.LiteralFormatting, Path: `["b"]`, Line: 2, Detail: `"""multi""" is written as 'multi'`},
+				{Feature: format.LiteralFormatting, Path: `["c"]`, Line: 3, Detail: `"esc\u0041" is written as 'escA'`},
+				{Feature: format.LiteralFormatting, Path: `["d"]`, Line: 4, Detail: `"basic" is written as 'basic'`},
+			},
+		},
+		{
+			name:     "datetimes",
+			input:    "a = 1979-05-27 07:32:00Z\nb = 1979-05-27T07:32:00Z\n",
+			document: "a = 1979-05-27T07:32:00Z\nb = 1979-05-27T07:32:00Z\n",
+			losses:   []format.Loss{{Feature: format.LiteralFormatting, Path: `["a"]`, Line: 1, Detail: "1979-05-27 07:32:00Z is written as 1979-05-27T07:32:00Z"}},
+		},
+		{
+			name:     "array tables",
+			input:    "[[t]]\nb = 1\na = 2\n[[t]]\na = 0x3\n",
+			document: "[[t]]\na = 2\nb = 1\n\n[[t]]\na = 3\n",
+			losses: []format.Loss{
+				{Feature: format.KeyOrder, Path: `["t"][0]["b"]`, Line: 2, Detail: `["t"][0]["b"] is moved after ["t"][0]["a"]`},
+				{Feature: format.LiteralFormatting, Path: `["t"][1]["a"]`, Line: 5, Detail: "0x3 is written as 3"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := format.Format(tt.input, eval.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Document != tt.document {
+				t.Errorf("document %q, want %q", r.Document, tt.document)
+			}
+			if !reflect.DeepEqual(r.Losses, tt.losses) {
+				t.Errorf("losses %#v\nwant %#v", r.Losses, tt.losses)
+			}
+		})
+	}
+}
+
+func TestFormatInvalid(t *testing.T) {
+	if _, err := format.Format("a = \n", eval.Options{}); err == nil {
+		t.Error("an invalid document is formatted")
+	}
+}
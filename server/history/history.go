@@ -0,0 +1,351 @@
+/*
+Package history records the playground states evaluated by each anonymous
+client session. The history of a session keeps the query, the hash of the
+input, the output flags and the outcome of each evaluation, and the inputs
+themselves so that the states can be restored. The states belong to the
+session alone: they are kept apart from the shared snippets and removed along
+with the history when it is cleared. This is why books do not save them to a
+snippet store, where anyone knowing an ID can fetch a snippet and where
+snippets are listed and exported. Books kept for a snippet directory live
+next to the snippets instead, in a subdirectory of their own.
+
+Histories are capped in the number of entries and in the size of the inputs
+kept per session, and in the total size of the inputs of all sessions, in
+which case the history of the least recently active session is dropped
+first. Pinned entries are never dropped to make room for new ones. Books kept
+in a directory write the history of every session to a JSON file of its own,
+so that histories outlive the server.
+*/
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/mdm-code/tqweb/server/snippet"
+)
+
+const (
+	// MaxEntries is the number of entries kept per session.
+	MaxEntries = 50
+
+	// MaxSessions is the number of sessions whose history is kept.
+	MaxSessions = 1024
+
+	// MaxBytes is the total size of the inputs kept for all sessions.
+	MaxBytes = 64 << 20
+
+	// MaxSessionBytes is the size of the inputs kept for a single session.
+	MaxSessionBytes = 4 << 20
+)
+
+var (
+	// ErrNotFound is returned for entries missing from the history.
+	ErrNotFound = errors.New("history entry not found")
+
+	// ErrTooLarge is returned when the input of an evaluation does not fit
+	// in the history of the session.
+	ErrTooLarge = errors.New("input too large for the history")
+)
+
+// validID matches the session IDs that are safe to use as file names.
+var validID = regexp.MustCompile(`^[0-9A-Za-z_-]+$`)
+
+// Entry is a single evaluation recorded in the history.
+type Entry struct {
+	ID        string       `json:"id"`
+	Query     string       `json:"query"`
+	InputHash string       `json:"inputHash"`
+	Options   eval.Options `json:"options"`
+	Outcome   string       `json:"outcome"`
+	Pinned    bool         `json:"pinned"`
+	Time      time.Time    `json:"time"`
+}
+
+// Outcome summarizes the results of an evaluation: the first error reported
+// by any of the queries or ok.
+func Outcome(results []eval.Result) string {
+	for _, r := range results {
+		if r.Error != "" {
+			return r.Error
+		}
+	}
+	return "ok"
+}
+
+// session is the history of a single session as it is saved to a file.
+type session struct {
+	Entries []Entry           `json:"entries"`
+	Inputs  map[string]string `json:"inputs"`
+	Used    time.Time         `json:"used"`
+	bytes   int
+}
+
+// Book holds the histories of all sessions. It is safe for concurrent use.
+type Book struct {
+	mu       sync.Mutex
+	dir      string
+	max      int
+	bytes    int
+	sessions map[string]*session
+}
+
+// New returns an empty book keeping up to max entries per session in memory
+// until the server stops.
+func New(max int) *Book {
+	return &Book{max: max, sessions: make(map[string]*session)}
+}
+
+// NewDir returns a book keeping up to max entries per session in the
+// directory, creating it if needed, and loads the histories saved in it.
+func NewDir(path string, max int) (*Book, error) {
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, err
+	}
+	b := &Book{dir: path, max: max, sessions: make(map[string]*session)}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if e.IsDir() || !ok || !validID.MatchString(id) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(path, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		ss := &session{}
+		if err := json.Unmarshal(data, ss); err != nil {
+			return nil, err
+		}
+		if ss.Inputs == nil {
+			ss.Inputs = make(map[string]string)
+		}
+		for _, input := range ss.Inputs {
+			ss.bytes += len(input)
+		}
+		b.sessions[id] = ss
+		b.bytes += ss.bytes
+	}
+	for len(b.sessions) > MaxSessions || b.bytes > MaxBytes {
+		if err := b.dropOldest(""); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Record puts the evaluated state on top of the session history. Evaluating
+// a state already in the history moves it to the top and keeps it pinned if
+// it was. States whose input does not fit in the history are not recorded.
+func (b *Book) Record(id string, s snippet.Snippet, outcome string) (Entry, error) {
+	sum := sha256.Sum256([]byte(s.Input))
+	e := Entry{
+		ID:        snippet.NewID(s.Query, s.Input, s.Options),
+		Query:     s.Query,
+		InputHash: hex.EncodeToString(sum[:8]),
+		Options:   s.Options,
+		Outcome:   outcome,
+		Time:      time.Now().UTC(),
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ss, err := b.session(id)
+	if err != nil {
+		return Entry{}, err
+	}
+	if i := index(ss.Entries, e.ID); i >= 0 {
+		e.Pinned = ss.Entries[i].Pinned
+		ss.Entries = slices.Delete(ss.Entries, i, i+1)
+	} else {
+		if ss.bytes+len(s.Input) > MaxSessionBytes && b.pinnedBytes(ss)+len(s.Input) > MaxSessionBytes {
+			return Entry{}, ErrTooLarge
+		}
+		ss.Inputs[e.ID] = s.Input
+		ss.bytes += len(s.Input)
+		b.bytes += len(s.Input)
+	}
+	ss.Entries = slices.Insert(ss.Entries, 0, e)
+	for i := len(ss.Entries) - 1; i > 0 && (len(ss.Entries) > b.max || ss.bytes > MaxSessionBytes); i-- {
+		if !ss.Entries[i].Pinned {
+			b.drop(ss, i)
+		}
+	}
+	for b.bytes > MaxBytes && len(b.sessions) > 1 {
+		if err := b.dropOldest(id); err != nil {
+			return Entry{}, err
+		}
+	}
+	return e, b.save(id, ss)
+}
+
+// Entries returns the history of the session from the most recent entry.
+func (b *Book) Entries(id string) []Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ss, ok := b.sessions[id]; ok {
+		return slices.Clone(ss.Entries)
+	}
+	return nil
+}
+
+// State returns the playground state of the entry of the session history.
+func (b *Book) State(id, entry string) (snippet.Snippet, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ss, ok := b.sessions[id]
+	if !ok {
+		return snippet.Snippet{}, ErrNotFound
+	}
+	i := index(ss.Entries, entry)
+	if i < 0 {
+		return snippet.Snippet{}, ErrNotFound
+	}
+	e := ss.Entries[i]
+	return snippet.Snippet{
+		ID:      e.ID,
+		Query:   e.Query,
+		Input:   ss.Inputs[e.ID],
+		Options: e.Options,
+		Created: e.Time,
+	}, nil
+}
+
+// Pin pins or unpins the entry of the session history.
+func (b *Book) Pin(id, entry string, pinned bool) (Entry, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ss, ok := b.sessions[id]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	i := index(ss.Entries, entry)
+	if i < 0 {
+		return Entry{}, ErrNotFound
+	}
+	ss.Entries[i].Pinned = pinned
+	return ss.Entries[i], b.save(id, ss)
+}
+
+// Clear removes the whole history of the session, pinned entries and the
+// inputs of the states included.
+func (b *Book) Clear(id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.remove(id)
+}
+
+// session returns the history of the session creating it if needed. It must
+// be called with the lock held.
+func (b *Book) session(id string) (*session, error) {
+	ss, ok := b.sessions[id]
+	if !ok {
+		if len(b.sessions) >= MaxSessions {
+			if err := b.dropOldest(id); err != nil {
+				return nil, err
+			}
+		}
+		ss = &session{Inputs: make(map[string]string)}
+		b.sessions[id] = ss
+	}
+	ss.Used = time.Now().UTC()
+	return ss, nil
+}
+
+// drop removes the entry of the session history along with its input.
+func (b *Book) drop(ss *session, i int) {
+	id := ss.Entries[i].ID
+	ss.Entries = slices.Delete(ss.Entries, i, i+1)
+	ss.bytes -= len(ss.Inputs[id])
+	b.bytes -= len(ss.Inputs[id])
+	delete(ss.Inputs, id)
+}
+
+func (b *Book) pinnedBytes(ss *session) int {
+	n := 0
+	for _, e := range ss.Entries {
+		if e.Pinned {
+			n += len(ss.Inputs[e.ID])
+		}
+	}
+	return n
+}
+
+// dropOldest removes the history of the least recently active session other
+// than the one with the kept ID.
+func (b *Book) dropOldest(kept string) error {
+	var oldest string
+	var used time.Time
+	for id, ss := range b.sessions {
+		if id != kept && (oldest == "" || ss.Used.Before(used)) {
+			oldest, used = id, ss.Used
+		}
+	}
+	if oldest == "" {
+		return nil
+	}
+	return b.remove(oldest)
+}
+
+func (b *Book) remove(id string) error {
+	ss, ok := b.sessions[id]
+	if !ok {
+		return nil
+	}
+	b.bytes -= ss.bytes
+	delete(b.sessions, id)
+	if b.dir == "" || !validID.MatchString(id) {
+		return nil
+	}
+	err := os.Remove(b.file(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// save writes the history of the session to its file in books kept in a
+// directory.
+func (b *Book) save(id string, ss *session) error {
+	if b.dir == "" || !validID.MatchString(id) {
+		return nil
+	}
+	data, err := json.Marshal(ss)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(b.dir, ".history-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), b.file(id))
+}
+
+func (b *Book) file(id string) string {
+	return filepath.Join(b.dir, id+".json")
+}
+
+func index(entries []Entry, id string) int {
+	return slices.IndexFunc(entries, func(e Entry) bool { return e.ID == id })
+}
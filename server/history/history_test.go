@@ -0,0 +1,159 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mdm-code/tqweb/server/snippet"
+)
+
+func state(i int) snippet.Snippet {
+	return snippet.Snippet{Query: fmt.Sprintf(`["k%d"]`, i), Input: fmt.Sprintf("k%d = %d\n", i, i)}
+}
+
+func TestRecord(t *testing.T) {
+	b := New(3)
+	first, err := b.Record("s", state(0), "ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Pin("s", first.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 4; i++ {
+		if _, err := b.Record("s", state(i), "ok"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries := b.Entries("s")
+	var queries []string
+	for _, e := range entries {
+		queries = append(queries, e.Query)
+	}
+	if got, want := strings.Join(queries, " "), `["k4"] ["k3"] ["k0"]`; got != want {
+		t.Errorf("entries %s, want %s", got, want)
+	}
+	if b.bytes != len(state(4).Input)+len(state(3).Input)+len(state(0).Input) {
+		t.Errorf("%d bytes kept after dropping entries", b.bytes)
+	}
+	again, err := b.Record("s", state(0), "ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Pinned || b.Entries("s")[0].ID != first.ID {
+		t.Errorf("recording a pinned state again: got %+v", b.Entries("s"))
+	}
+	s, err := b.State("s", first.ID)
+	if err != nil || s.Input != state(0).Input || s.Query != state(0).Query {
+		t.Errorf("State() = %+v, %v", s, err)
+	}
+	if _, err := b.State("other", first.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("State() of another session = %v, want ErrNotFound", err)
+	}
+}
+
+func TestClear(t *testing.T) {
+	b := New(MaxEntries)
+	e, err := b.Record("s", state(0), "ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Clear("s"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.State("s", e.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("State() after Clear = %v, want ErrNotFound", err)
+	}
+	if b.bytes != 0 || len(b.Entries("s")) != 0 {
+		t.Errorf("%d bytes and %d entries kept after Clear", b.bytes, len(b.Entries("s")))
+	}
+}
+
+func TestRecordTooLarge(t *testing.T) {
+	b := New(MaxEntries)
+	large := snippet.Snippet{Query: ".", Input: strings.Repeat("#", MaxSessionBytes+1)}
+	if _, err := b.Record("s", large, "ok"); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Record() = %v, want ErrTooLarge", err)
+	}
+	half := func(c string) snippet.Snippet {
+		return snippet.Snippet{Query: c, Input: strings.Repeat(c, MaxSessionBytes/2)}
+	}
+	for _, c := range []string{"a", "b", "c"} {
+		if _, err := b.Record("s", half(c), "ok"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if entries := b.Entries("s"); len(entries) != 2 || entries[0].Query != "c" || entries[1].Query != "b" {
+		t.Errorf("entries %+v, want c and b", entries)
+	}
+	for _, e := range b.Entries("s") {
+		if _, err := b.Pin("s", e.ID, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := b.Record("s", half("d"), "ok"); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Record() over pinned entries = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestRecordDropsOldestSession(t *testing.T) {
+	b := New(MaxEntries)
+	per := MaxSessionBytes / 2
+	sessions := MaxBytes/per + 1
+	for i := range sessions {
+		s := snippet.Snippet{Query: ".", Input: strings.Repeat("#", per)}
+		if _, err := b.Record(fmt.Sprint(i), s, "ok"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b.bytes > MaxBytes {
+		t.Errorf("%d bytes kept, want at most %d", b.bytes, MaxBytes)
+	}
+	if b.Entries("0") != nil {
+		t.Error("the history of the oldest session was kept")
+	}
+	if b.Entries(fmt.Sprint(sessions-1)) == nil {
+		t.Error("the history of the newest session was dropped")
+	}
+}
+
+func TestNewDir(t *testing.T) {
+	dir := t.TempDir()
+	b, err := NewDir(dir, MaxEntries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := b.Record("kept", state(0), "ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Pin("kept", kept.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Record("cleared", state(1), "ok"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Clear("cleared"); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = NewDir(dir, MaxEntries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := b.Entries("kept")
+	if len(entries) != 1 || entries[0].ID != kept.ID || !entries[0].Pinned {
+		t.Errorf("entries after reopening %+v", entries)
+	}
+	if s, err := b.State("kept", kept.ID); err != nil || s.Input != state(0).Input {
+		t.Errorf("State() after reopening = %+v, %v", s, err)
+	}
+	if b.Entries("cleared") != nil {
+		t.Error("a cleared history was loaded")
+	}
+	if b.bytes != len(state(0).Input) {
+		t.Errorf("%d bytes loaded", b.bytes)
+	}
+}
@@ -0,0 +1,33 @@
+package i18n
+
+import (
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// explanations maps fragments of the tq error messages to the keys of their
+// explanations. The first matching fragment wins.
+var explanations = []struct {
+	fragment string
+	key      string
+}{
+	{"disallowed character", "explain.disallowed_character"},
+	{"unterminated string literal", "explain.unterminated_string"},
+	{"expected '.' or '[' to parse query element", "explain.query_element"},
+	{"to terminate selector", "explain.unterminated_selector"},
+	{"cannot query", "explain.wrong_type"},
+	{"failed to unmarshal TOML input", "explain.toml_input"},
+	{"failed to marshal TOML output", "explain.toml_output"},
+}
+
+// Explain returns the explanation of the tq error message in the language or
+// an empty string for errors tqweb has no explanation for.
+func Explain(tag language.Tag, message string) string {
+	for _, e := range explanations {
+		if strings.Contains(message, e.fragment) {
+			return T(tag, e.key)
+		}
+	}
+	return ""
+}
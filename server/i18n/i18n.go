@@ -0,0 +1,172 @@
+/*
+Package i18n translates the tqweb user interface. Messages live in catalogs
+embedded as TOML files, one per language, with tables grouping the messages
+of a page. A message is looked up by its dotted key, for example nav.diff for
+the diff key of the nav table. English is the reference catalog: messages
+missing from another catalog fall back to English.
+
+The content of examples, lessons and the filter reference is not translated;
+only the interface around it and the tqweb explanations of tq errors are.
+*/
+package i18n
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+
+	gotoml "github.com/pelletier/go-toml/v2"
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+)
+
+// Supported lists the languages of the catalogs. The first one is the
+// fallback.
+var Supported = []language.Tag{language.English, language.Polish}
+
+var matcher = language.NewMatcher(Supported)
+
+//go:embed catalog/*.toml
+var catalogFS embed.FS
+
+var (
+	once     sync.Once
+	catalogs map[language.Tag]map[string]string
+	loadErr  error
+)
+
+func load() (map[language.Tag]map[string]string, error) {
+	once.Do(func() {
+		catalogs, loadErr = parse(catalogFS)
+	})
+	return catalogs, loadErr
+}
+
+func parse(fsys fs.FS) (map[language.Tag]map[string]string, error) {
+	files, err := fs.Glob(fsys, "catalog/*.toml")
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[language.Tag]map[string]string, len(files))
+	for _, f := range files {
+		tag, err := language.Parse(strings.TrimSuffix(path.Base(f), ".toml"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		b, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return nil, err
+		}
+		var tables map[string]any
+		if err := gotoml.Unmarshal(b, &tables); err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		messages := make(map[string]string)
+		if err := flatten("", tables, messages); err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		result[tag] = messages
+	}
+	return result, nil
+}
+
+// flatten turns the nested tables of a catalog into dotted keys.
+func flatten(prefix string, tables map[string]any, messages map[string]string) error {
+	for k, v := range tables {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch v := v.(type) {
+		case string:
+			messages[key] = v
+		case map[string]any:
+			if err := flatten(key, v, messages); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("message %s is not a string", key)
+		}
+	}
+	return nil
+}
+
+// Match picks the supported language best matching the override, if it is
+// set, and the Accept-Language header otherwise.
+func Match(override, acceptLanguage string) language.Tag {
+	if override != "" {
+		if tag, err := language.Parse(override); err == nil {
+			if _, i, c := matcher.Match(tag); c != language.No {
+				return Supported[i]
+			}
+		}
+	}
+	tags, _, _ := language.ParseAcceptLanguage(acceptLanguage)
+	_, i, _ := matcher.Match(tags...)
+	return Supported[i]
+}
+
+// T returns the message under the key in the language formatted with the
+// arguments. The English message is used when the language lacks it, and
+// the key itself when there is no English message either.
+func T(tag language.Tag, key string, args ...any) string {
+	catalogs, err := load()
+	if err != nil {
+		return key
+	}
+	msg, ok := catalogs[tag][key]
+	if !ok {
+		if msg, ok = catalogs[Supported[0]][key]; !ok {
+			return key
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// N returns the plural form of the message under the key matching the count
+// n in the language. The forms are kept under the one, few, many and other
+// subkeys of the key, and the message is formatted with n.
+func N(tag language.Tag, key string, n int) string {
+	form := "other"
+	switch plural.Cardinal.MatchPlural(tag, n, 0, 0, 0, 0) {
+	case plural.One:
+		form = "one"
+	case plural.Few:
+		form = "few"
+	case plural.Many:
+		form = "many"
+	}
+	return T(tag, key+"."+form, n)
+}
+
+// Has reports whether the English catalog has the key.
+func Has(key string) bool {
+	catalogs, err := load()
+	if err != nil {
+		return false
+	}
+	_, ok := catalogs[Supported[0]][key]
+	return ok
+}
+
+type contextKey struct{}
+
+// WithLanguage returns a copy of the context carrying the language.
+func WithLanguage(ctx context.Context, tag language.Tag) context.Context {
+	return context.WithValue(ctx, contextKey{}, tag)
+}
+
+// FromContext returns the language carried by the context or English.
+func FromContext(ctx context.Context) language.Tag {
+	if tag, ok := ctx.Value(contextKey{}).(language.Tag); ok {
+		return tag
+	}
+	return Supported[0]
+}
@@ -0,0 +1,185 @@
+package i18n
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mdm-code/tq"
+	"github.com/mdm-code/tq/toml"
+	"golang.org/x/text/language"
+)
+
+// difference returns the sorted keys of a missing from b.
+func difference(a, b map[string]string) []string {
+	var keys []string
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// checkCatalogs reports the catalogs of the supported languages that lack
+// keys of the English one or have keys it does not.
+func checkCatalogs(t *testing.T, catalogs map[language.Tag]map[string]string) {
+	t.Helper()
+	reference := catalogs[Supported[0]]
+	for _, tag := range Supported[1:] {
+		catalog, ok := catalogs[tag]
+		if !ok {
+			t.Errorf("no catalog for %s", tag)
+			continue
+		}
+		if missing := difference(reference, catalog); len(missing) > 0 {
+			t.Errorf("%s catalog is missing keys: %v", tag, missing)
+		}
+		if unknown := difference(catalog, reference); len(unknown) > 0 {
+			t.Errorf("%s catalog has unknown keys: %v", tag, unknown)
+		}
+	}
+}
+
+func TestCatalogs(t *testing.T) {
+	catalogs, err := load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCatalogs(t, catalogs)
+}
+
+func TestCheckCatalogsReportsMissingKeys(t *testing.T) {
+	catalogs, err := parse(fstest.MapFS{
+		"catalog/en.toml": {Data: []byte("[nav]\nhome = \"Home\"\ndiff = \"Diff\"\n")},
+		"catalog/pl.toml": {Data: []byte("[nav]\nhome = \"Start\"\nextra = \"Dodatek\"\n")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := difference(catalogs[language.English], catalogs[language.Polish]); !slices.Equal(got, []string{"nav.diff"}) {
+		t.Errorf("missing keys = %v, want [nav.diff]", got)
+	}
+	if got := difference(catalogs[language.Polish], catalogs[language.English]); !slices.Equal(got, []string{"nav.extra"}) {
+		t.Errorf("unknown keys = %v, want [nav.extra]", got)
+	}
+}
+
+func TestParseRejectsNonStrings(t *testing.T) {
+	_, err := parse(fstest.MapFS{"catalog/en.toml": {Data: []byte("[nav]\nhome = 1\n")}})
+	if err == nil {
+		t.Error("parse succeeded, want an error")
+	}
+}
+
+func TestT(t *testing.T) {
+	if got := T(language.Polish, "nav.diff"); got == "" || got == "nav.diff" {
+		t.Errorf("T(pl, nav.diff) = %q", got)
+	}
+	if got := T(language.Polish, "no.such.key"); got != "no.such.key" {
+		t.Errorf("T(pl, no.such.key) = %q, want the key", got)
+	}
+}
+
+func TestN(t *testing.T) {
+	tests := []struct {
+		tag  language.Tag
+		n    int
+		want string
+	}{
+		{language.English, 1, "1 result"},
+		{language.English, 2, "2 results"},
+		{language.Polish, 1, "1 wynik"},
+		{language.Polish, 3, "3 wyniki"},
+		{language.Polish, 5, "5 wyników"},
+		{language.Polish, 22, "22 wyniki"},
+	}
+	for _, tt := range tests {
+		if got := N(tt.tag, "results.count", tt.n); got != tt.want {
+			t.Errorf("N(%s, %d) = %q, want %q", tt.tag, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		override, accept string
+		want             language.Tag
+	}{
+		{"", "pl-PL,pl;q=0.9,en;q=0.8", language.Polish},
+		{"", "de-DE", language.English},
+		{"en", "pl", language.English},
+		{"xx-invalid-", "pl", language.Polish},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.override, tt.accept); got != tt.want {
+			t.Errorf("Match(%q, %q) = %s, want %s", tt.override, tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if got := FromContext(context.Background()); got != language.English {
+		t.Errorf("FromContext(background) = %s, want en", got)
+	}
+	ctx := WithLanguage(context.Background(), language.Polish)
+	if got := FromContext(ctx); got != language.Polish {
+		t.Errorf("FromContext = %s, want pl", got)
+	}
+}
+
+// failingEncoder decodes TOML but fails to encode any result.
+type failingEncoder struct{ toml.GoTOML }
+
+func (failingEncoder) Encode(any) ([]byte, error) {
+	return nil, errors.New("cannot encode")
+}
+
+// tqError returns the message of the error tq reports for the query run
+// against the input.
+func tqError(t *testing.T, adapted toml.DecodeEncoder, input, query string) string {
+	t.Helper()
+	err := tq.New(toml.NewAdapter(adapted)).Run(strings.NewReader(input), io.Discard, query)
+	if err == nil {
+		t.Fatalf("query %s against %q succeeded", query, input)
+	}
+	return err.Error()
+}
+
+func TestExplain(t *testing.T) {
+	goTOML := toml.NewGoTOML(toml.GoTOMLConf{})
+	tests := []struct {
+		adapted      toml.DecodeEncoder
+		input, query string
+		key          string
+	}{
+		{goTOML, "a = 1", `.a-b`, "explain.disallowed_character"},
+		{goTOML, "a = 1", `["a`, "explain.unterminated_string"},
+		{goTOML, "a = 1", `]`, "explain.query_element"},
+		{goTOML, "a = 1", `["a"`, "explain.unterminated_selector"},
+		{goTOML, "a = 1", `["a"][0]`, "explain.wrong_type"},
+		{goTOML, "a = [1]", `["a"]["b"]`, "explain.wrong_type"},
+		{goTOML, "a = ", `.`, "explain.toml_input"},
+		{failingEncoder{goTOML}, "a = 1", `.`, "explain.toml_output"},
+	}
+	for _, tt := range tests {
+		message := tqError(t, tt.adapted, tt.input, tt.query)
+		for _, tag := range Supported {
+			want := T(tag, tt.key)
+			if want == tt.key {
+				t.Fatalf("no %s message in %s", tt.key, tag)
+			}
+			if got := Explain(tag, message); got != want {
+				t.Errorf("Explain(%s, %q) = %q, want %q", tag, message, got, want)
+			}
+		}
+	}
+	if got := Explain(language.English, "context deadline exceeded"); got != "" {
+		t.Errorf("Explain of an unknown error = %q, want none", got)
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Package lesson provides the ordered tq tutorial served by tqweb. Lessons are
+stored as TOML manifests embedded in the binary, so adding a lesson comes down
+to dropping another manifest into the manifest directory. Each lesson keeps
+its solution query hidden from the learner and grades submissions by comparing
+the values both queries evaluate to.
+*/
+package lesson
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mdm-code/tq/toml"
+	"github.com/mdm-code/tqweb/server/eval"
+)
+
+const manifestDir = "manifest"
+
+//go:embed manifest/*.toml
+var manifests embed.FS
+
+// ErrNotFound is returned when no lesson matches the requested ID.
+var ErrNotFound = errors.New("lesson not found")
+
+// Lesson is a single exercise of the tutorial.
+type Lesson struct {
+	ID       string   `toml:"-"`
+	Order    int      `toml:"order"`
+	Title    string   `toml:"title"`
+	Prompt   string   `toml:"prompt"`
+	Input    string   `toml:"input"`
+	Solution string   `toml:"solution"`
+	Hints    []string `toml:"hints"`
+}
+
+// Grade is the outcome of checking a submitted query against a lesson.
+type Grade struct {
+	Passed    bool
+	Diagnosis string
+	Hints     []string
+}
+
+// Grade evaluates the submitted query and compares its results with the
+// results of the lesson solution.
+func (l Lesson) Grade(query string) Grade {
+	fail := func(format string, args ...any) Grade {
+		return Grade{Diagnosis: fmt.Sprintf(format, args...), Hints: l.Hints}
+	}
+	if err := eval.Validate(query); err != nil {
+		return fail("The query is not valid: %s", err)
+	}
+	have, err := eval.Values(query, l.Input, eval.Options{})
+	if err != nil {
+		return fail("The query failed on the input: %s", err)
+	}
+	want, err := eval.Values(l.Solution, l.Input, eval.Options{})
+	if err != nil {
+		return fail("The lesson solution failed: %s", err)
+	}
+	if len(have) != len(want) {
+		return fail("The query returned %d result(s), but the lesson expects %d.", len(have), len(want))
+	}
+	if !eval.Equal(have, want) {
+		return fail("The query returned the right number of results, but their values differ.")
+	}
+	return Grade{Passed: true}
+}
+
+var (
+	loadOnce sync.Once
+	lessons  []Lesson
+	loadErr  error
+)
+
+// All returns all lessons in the order they should be taken.
+func All() ([]Lesson, error) {
+	loadOnce.Do(func() {
+		lessons, loadErr = load(manifests)
+	})
+	return lessons, loadErr
+}
+
+// Get returns the lesson with the given ID.
+func Get(id string) (Lesson, error) {
+	all, err := All()
+	if err != nil {
+		return Lesson{}, err
+	}
+	for _, l := range all {
+		if l.ID == id {
+			return l, nil
+		}
+	}
+	return Lesson{}, ErrNotFound
+}
+
+// Next returns the lesson that follows the one with the given ID. It reports
+// false when the given lesson is the last one.
+func Next(id string) (Lesson, bool) {
+	all, err := All()
+	if err != nil {
+		return Lesson{}, false
+	}
+	for i, l := range all {
+		if l.ID == id && i+1 < len(all) {
+			return all[i+1], true
+		}
+	}
+	return Lesson{}, false
+}
+
+func load(fsys fs.FS) ([]Lesson, error) {
+	entries, err := fs.ReadDir(fsys, manifestDir)
+	if err != nil {
+		return nil, err
+	}
+	decoder := toml.NewGoTOML(toml.GoTOMLConf{})
+	result := make([]Lesson, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		data, err := fs.ReadFile(fsys, path.Join(manifestDir, name))
+		if err != nil {
+			return nil, err
+		}
+		var l Lesson
+		if err := decoder.Decode(bytes.NewReader(data), &l); err != nil {
+			return nil, fmt.Errorf("lesson %s: %w", name, err)
+		}
+		l.ID = strings.TrimSuffix(name, path.Ext(name))
+		result = append(result, l)
+	}
+	slices.SortStableFunc(result, func(a, b Lesson) int {
+		return a.Order - b.Order
+	})
+	return result, nil
+}
@@ -0,0 +1,88 @@
+package lesson_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mdm-code/tqweb/server/lesson"
+)
+
+func TestSolutions(t *testing.T) {
+	all, err := lesson.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) == 0 {
+		t.Fatal("no lessons")
+	}
+	for _, l := range all {
+		if g := l.Grade(l.Solution); !g.Passed {
+			t.Errorf("%s: the solution fails: %s", l.ID, g.Diagnosis)
+		}
+	}
+}
+
+func TestGrade(t *testing.T) {
+	l := lesson.Lesson{
+		Input:    "[servers.alpha]\nip = \"10.0.0.1\"\ndc = \"eqdc10\"\n\n[servers.beta]\nip = \"10.0.0.2\"\ndc = \"eqdc10\"\n",
+		Solution: `["servers"][]["ip"]`,
+		Hints:    []string{"Iterate over the servers."},
+	}
+	tests := []struct {
+		query     string
+		passed    bool
+		diagnosis string
+	}{
+		{`["servers"][]["ip"]`, true, ""},
+		{`.["servers"] [] ["ip"]`, true, ""},
+		{`["servers"]["alpha"]["ip"]`, false, "The query returned 1 result(s), but the lesson expects 2."},
+		{`["servers"][]["dc"]`, false, "The query returned the right number of results, but their values differ."},
+		{`["servers"][0]`, false, "The query failed on the input"},
+		{`["servers"`, false, "The query is not valid"},
+		{`$`, false, "The query is not valid"},
+	}
+	for _, tt := range tests {
+		g := l.Grade(tt.query)
+		if g.Passed != tt.passed || !strings.HasPrefix(g.Diagnosis, tt.diagnosis) {
+			t.Errorf("Grade(%s) = %v, %q, want %v, %q", tt.query, g.Passed, g.Diagnosis, tt.passed, tt.diagnosis)
+		}
+		if hints := !tt.passed; hints != slices.Equal(g.Hints, l.Hints) {
+			t.Errorf("Grade(%s) hints %v", tt.query, g.Hints)
+		}
+	}
+}
+
+func TestParseProgress(t *testing.T) {
+	all, err := lesson.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := all[0].ID, all[1].ID
+	tests := []struct {
+		cookie string
+		want   lesson.Progress
+	}{
+		{"", nil},
+		{",,,", nil},
+		{first + "," + second, lesson.Progress{first, second}},
+		{first + ",," + first + "," + second + ",", lesson.Progress{first, second}},
+		{"no-such-lesson," + second, lesson.Progress{second}},
+		{" " + first + ";" + second, nil},
+		{"../" + first, nil},
+		{strings.Repeat(",", 10000), nil},
+	}
+	for _, tt := range tests {
+		p := lesson.ParseProgress(tt.cookie)
+		if !slices.Equal(p, tt.want) {
+			t.Errorf("ParseProgress(%.40q) = %v, want %v", tt.cookie, p, tt.want)
+		}
+	}
+	p := lesson.ParseProgress(first).Complete(second).Complete(first)
+	if p.String() != first+","+second || !p.Done(second) || p.Done("no-such-lesson") {
+		t.Errorf("progress %v", p)
+	}
+	if q := lesson.ParseProgress(p.String()); !slices.Equal(q, p) {
+		t.Errorf("progress %v does not round-trip: %v", p, q)
+	}
+}
@@ -0,0 +1,40 @@
+package lesson
+
+import (
+	"slices"
+	"strings"
+)
+
+// Progress is the set of lesson IDs the learner has completed.
+type Progress []string
+
+// ParseProgress reads the progress from its cookie representation. Empty
+// and repeated IDs are skipped, and so are the IDs of lessons that do not
+// exist.
+func ParseProgress(s string) Progress {
+	var p Progress
+	for _, id := range strings.Split(s, ",") {
+		if _, err := Get(id); err == nil {
+			p = p.Complete(id)
+		}
+	}
+	return p
+}
+
+// Done reports whether the lesson with the given ID has been completed.
+func (p Progress) Done(id string) bool {
+	return slices.Contains(p, id)
+}
+
+// Complete marks the lesson with the given ID as completed.
+func (p Progress) Complete(id string) Progress {
+	if p.Done(id) {
+		return p
+	}
+	return append(p, id)
+}
+
+// String returns the cookie representation of the progress.
+func (p Progress) String() string {
+	return strings.Join(p, ",")
+}
@@ -0,0 +1,121 @@
+/*
+Package live keeps track of the evaluations started by each client session of
+the live-as-you-type playground. Starting a new evaluation cancels the one
+still in flight for the same session and waits for it to wind down, so there
+is never more than one evaluation running per session. The last decoded input
+of a session is kept around as well, so typing in the query field does not
+decode the same input over and over.
+*/
+package live
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/mdm-code/tqweb/server/eval"
+)
+
+// MaxCachedDocuments is the number of sessions whose last decoded input is
+// kept in memory.
+const MaxCachedDocuments = 64
+
+type call struct {
+	seq    uint64
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type cached struct {
+	sum  [sha256.Size]byte
+	doc  eval.Document
+	used time.Time
+}
+
+// Sessions tracks the evaluation in flight for every client session.
+type Sessions struct {
+	mu    sync.Mutex
+	seq   uint64
+	calls map[string]call
+	docs  map[string]cached
+}
+
+// NewSessions returns an empty session tracker.
+func NewSessions() *Sessions {
+	return &Sessions{
+		calls: make(map[string]call),
+		docs:  make(map[string]cached),
+	}
+}
+
+// Begin cancels the evaluation in flight for the session, waits for it to
+// return and returns the context of the new one. The returned function must
+// be called once the evaluation is over; it reports whether the result is
+// stale because a newer evaluation was started for the session in the
+// meantime.
+func (s *Sessions) Begin(parent context.Context, session string) (context.Context, func() bool) {
+	ctx, cancel := context.WithCancel(parent)
+	done := make(chan struct{})
+	s.mu.Lock()
+	s.seq++
+	seq := s.seq
+	prev, running := s.calls[session]
+	s.calls[session] = call{seq: seq, cancel: cancel, done: done}
+	s.mu.Unlock()
+	if running {
+		prev.cancel()
+		select {
+		case <-prev.done:
+		case <-ctx.Done():
+		}
+	}
+	return ctx, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		stale := true
+		if cur, ok := s.calls[session]; ok && cur.seq == seq {
+			stale = ctx.Err() != nil
+			delete(s.calls, session)
+		}
+		cancel()
+		close(done)
+		return stale
+	}
+}
+
+// Decode decodes the input of the session. The document decoded for the
+// previous evaluation of the session is reused if the input has not changed.
+func (s *Sessions) Decode(ctx context.Context, session, input string) (eval.Document, error) {
+	sum := sha256.Sum256([]byte(input))
+	s.mu.Lock()
+	c, ok := s.docs[session]
+	s.mu.Unlock()
+	if ok && c.sum == sum {
+		s.store(session, c)
+		return c.doc, nil
+	}
+	doc, err := eval.DecodeContext(ctx, input)
+	if err != nil {
+		return doc, err
+	}
+	s.store(session, cached{sum: sum, doc: doc})
+	return doc, nil
+}
+
+func (s *Sessions) store(session string, c cached) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c.used = time.Now()
+	s.docs[session] = c
+	if len(s.docs) <= MaxCachedDocuments {
+		return
+	}
+	var oldest string
+	for id, d := range s.docs {
+		if oldest == "" || d.used.Before(s.docs[oldest].used) {
+			oldest = id
+		}
+	}
+	delete(s.docs, oldest)
+}
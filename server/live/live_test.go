@@ -0,0 +1,64 @@
+package live_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mdm-code/tqweb/server/live"
+)
+
+func TestBeginCancelsPrevious(t *testing.T) {
+	s := live.NewSessions()
+	first, firstDone := s.Begin(context.Background(), "a")
+	var over atomic.Bool
+	stale := make(chan bool, 1)
+	go func() {
+		<-first.Done()
+		// The next evaluation must not start before this one is over.
+		time.Sleep(10 * time.Millisecond)
+		over.Store(true)
+		stale <- firstDone()
+	}()
+	second, secondDone := s.Begin(context.Background(), "a")
+	if !over.Load() {
+		t.Fatal("Begin() returned before the previous evaluation was over")
+	}
+	if !<-stale {
+		t.Error("the cancelled evaluation is not stale")
+	}
+	if second.Err() != nil {
+		t.Errorf("the new evaluation is cancelled: %v", second.Err())
+	}
+	if secondDone() {
+		t.Error("the latest evaluation is stale")
+	}
+}
+
+func TestBeginKeepsOtherSessions(t *testing.T) {
+	s := live.NewSessions()
+	a, aDone := s.Begin(context.Background(), "a")
+	b, bDone := s.Begin(context.Background(), "b")
+	if a.Err() != nil || b.Err() != nil {
+		t.Fatalf("evaluations cancelled: %v, %v", a.Err(), b.Err())
+	}
+	if aDone() || bDone() {
+		t.Error("evaluations of different sessions are stale")
+	}
+}
+
+func TestBeginGivesUpWaiting(t *testing.T) {
+	s := live.NewSessions()
+	_, firstDone := s.Begin(context.Background(), "a")
+	defer firstDone()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	second, secondDone := s.Begin(ctx, "a")
+	if second.Err() == nil {
+		t.Error("the evaluation of a cancelled request is not cancelled")
+	}
+	if !secondDone() {
+		t.Error("the evaluation of a cancelled request is not stale")
+	}
+}
@@ -0,0 +1,250 @@
+/*
+Package mcp serves the tqweb evaluation engine over the Model Context
+Protocol. Messages are JSON-RPC 2.0 requests and responses exchanged one per
+line over a pair of streams, usually the standard input and output of the
+tqweb mcp command. The server answers the initialize handshake, ping and the
+tools/list and tools/call methods; the tools it offers are described in
+tools.go.
+*/
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/mdm-code/tqweb/server/upload"
+)
+
+// MaxMessageSize is the size in bytes of the largest message the server
+// reads. It leaves room for a TOML document of the size accepted for uploads
+// once it is escaped as a JSON string.
+const MaxMessageSize = 2 * upload.MaxSize
+
+// ProtocolVersions are the revisions of the protocol the server speaks, the
+// latest one first.
+var ProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// JSON-RPC error codes.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// ErrMessageTooLarge is returned when a message exceeds MaxMessageSize.
+var ErrMessageTooLarge = errors.New("message too large")
+
+// Request is a JSON-RPC request. Requests without an ID are notifications
+// and get no response.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is a JSON-RPC response carrying either a result or an error.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// Implementation names the server in the initialize handshake.
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// InitializeResult is the result of the initialize method.
+type InitializeResult struct {
+	ProtocolVersion string         `json:"protocolVersion"`
+	Capabilities    Capabilities   `json:"capabilities"`
+	ServerInfo      Implementation `json:"serverInfo"`
+	Instructions    string         `json:"instructions,omitempty"`
+}
+
+// Capabilities lists what the server offers to the client.
+type Capabilities struct {
+	Tools *ToolsCapability `json:"tools,omitempty"`
+}
+
+// ToolsCapability tells the client whether the list of tools changes.
+type ToolsCapability struct {
+	ListChanged bool `json:"listChanged"`
+}
+
+const instructions = "Run tq queries against TOML documents with the same " +
+	"evaluation engine as the tqweb playground. Use toml_paths to discover " +
+	"the queries selecting the values of a document."
+
+// Server answers the requests of a single client.
+type Server struct {
+	info  Implementation
+	tools []Tool
+}
+
+// New returns a server introducing itself with the version and offering the
+// tqweb tools.
+func New(version string) *Server {
+	return &Server{
+		info:  Implementation{Name: "tqweb", Version: version},
+		tools: Tools(),
+	}
+}
+
+// Serve reads requests from r and writes the responses to w until r is
+// exhausted or the context is done. Requests are handled one at a time in
+// the order they arrive. Serve returns nil once r is exhausted.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), MaxMessageSize)
+	enc := json.NewEncoder(w)
+	for sc.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if resp := s.handle(ctx, line); resp != nil {
+			if err := enc.Encode(resp); err != nil {
+				return err
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return fmt.Errorf("%w: over %d bytes", ErrMessageTooLarge, MaxMessageSize)
+		}
+		return err
+	}
+	return nil
+}
+
+// handle answers a single message and returns nil for notifications.
+func (s *Server) handle(ctx context.Context, msg []byte) *Response {
+	if msg[0] == '[' {
+		// Batches were dropped from the protocol and are not supported.
+		return &Response{
+			JSONRPC: "2.0",
+			ID:      json.RawMessage("null"),
+			Error:   &Error{Code: CodeInvalidRequest, Message: "Invalid request", Data: "batches are not supported"},
+		}
+	}
+	var req Request
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return &Response{
+			JSONRPC: "2.0",
+			ID:      json.RawMessage("null"),
+			Error:   &Error{Code: CodeParseError, Message: "Parse error", Data: err.Error()},
+		}
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		id := req.ID
+		if id == nil {
+			id = json.RawMessage("null")
+		}
+		return &Response{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error:   &Error{Code: CodeInvalidRequest, Message: "Invalid request"},
+		}
+	}
+	result, err := s.call(ctx, req)
+	if req.ID == nil {
+		return nil
+	}
+	resp := &Response{JSONRPC: "2.0", ID: req.ID, Result: result}
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &Error{Code: CodeInternalError, Message: "Internal error", Data: err.Error()}
+		}
+		resp.Result, resp.Error = nil, rpcErr
+	}
+	return resp
+}
+
+func (s *Server) call(ctx context.Context, req Request) (any, error) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		version := ProtocolVersions[0]
+		if slices.Contains(ProtocolVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		return InitializeResult{
+			ProtocolVersion: version,
+			Capabilities:    Capabilities{Tools: &ToolsCapability{}},
+			ServerInfo:      s.info,
+			Instructions:    instructions,
+		}, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return struct {
+			Tools []Tool `json:"tools"`
+		}{s.tools}, nil
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		i := slices.IndexFunc(s.tools, func(t Tool) bool { return t.Name == params.Name })
+		if i < 0 {
+			return nil, &Error{Code: CodeInvalidParams, Message: "Unknown tool", Data: params.Name}
+		}
+		args := params.Arguments
+		if len(args) == 0 || string(args) == "null" {
+			args = json.RawMessage("{}")
+		}
+		return s.tools[i].call(ctx, args)
+	}
+	if strings.HasPrefix(req.Method, "notifications/") {
+		return nil, nil
+	}
+	return nil, &Error{Code: CodeMethodNotFound, Message: "Method not found", Data: req.Method}
+}
+
+// decodeParams decodes the parameters of a request, leaving v untouched when
+// there are none.
+func decodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &Error{Code: CodeInvalidParams, Message: "Invalid params", Data: err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mdm-code/tqweb/server/eval"
+)
+
+// exchange is a message sent to the server along with a check of the
+// response. Notifications have no check since they get no response.
+type exchange struct {
+	request string
+	check   func(Response) error
+}
+
+// TestSession plays a session through the standard input and output of the
+// server, the way a client would, and checks every response.
+func TestSession(t *testing.T) {
+	const version = "v1.2.3"
+	s := New(version)
+	script := []exchange{
+		{`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"verify","version":"0"}}}`, func(r Response) error {
+			var res InitializeResult
+			if err := result(r, &res); err != nil {
+				return err
+			}
+			if res.ProtocolVersion != "2024-11-05" || res.ServerInfo.Version != version || res.Capabilities.Tools == nil {
+				return fmt.Errorf("initialize: got %+v", res)
+			}
+			return nil
+		}},
+		{`{"jsonrpc":"2.0","method":"notifications/initialized"}`, nil},
+		{`{"jsonrpc":"2.0","id":"ping","method":"ping"}`, func(r Response) error {
+			var res struct{}
+			return result(r, &res)
+		}},
+		{`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`, func(r Response) error {
+			var res struct {
+				Tools []Tool `json:"tools"`
+			}
+			if err := result(r, &res); err != nil {
+				return err
+			}
+			var got, want []string
+			for _, t := range res.Tools {
+				got = append(got, t.Name)
+			}
+			for _, t := range s.tools {
+				want = append(want, t.Name)
+			}
+			if !reflect.DeepEqual(got, want) {
+				return fmt.Errorf("tools/list: got %v, want %v", got, want)
+			}
+			return nil
+		}},
+		{`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"tq_query","arguments":{"query":".[\"a\"]","queries":[{"name":"bad","query":".[\"a\"][\"b\"]"}],"toml":"a = 1\n"}}}`, func(r Response) error {
+			var res struct {
+				StructuredContent QueryResults `json:"structuredContent"`
+				IsError           bool         `json:"isError"`
+			}
+			if err := result(r, &res); err != nil {
+				return err
+			}
+			out := res.StructuredContent
+			if !res.IsError || len(out.Results) != 2 || out.Results[0].Output != "1\n" ||
+				len(out.Diagnostics) != 1 || out.Diagnostics[0].Name != "bad" {
+				return fmt.Errorf("tq_query: got %+v", res)
+			}
+			return nil
+		}},
+		{`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"tq_validate","arguments":{"query":".[\"a\"] | ["}}}`, func(r Response) error {
+			return diagnosed(r, "tq_validate", Diagnostic{Source: "tq", Column: 8})
+		}},
+		{`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"toml_validate","arguments":{"toml":"a = 1\nb = \n"}}}`, func(r Response) error {
+			return diagnosed(r, "toml_validate", Diagnostic{Source: "toml", Line: 2, Column: 5})
+		}},
+		{`{"jsonrpc":"2.0","id":6,"method":"tools/call","params":{"name":"toml_paths","arguments":{"toml":"c = {}\n[a]\nb = [1, \"x\"]\n"}}}`, func(r Response) error {
+			var res struct {
+				StructuredContent Paths `json:"structuredContent"`
+			}
+			if err := result(r, &res); err != nil {
+				return err
+			}
+			want := []Path{
+				{Path: `["a"]["b"][0]`, Type: "integer"},
+				{Path: `["a"]["b"][1]`, Type: "string"},
+				{Path: `["c"]`, Type: "table"},
+			}
+			if !reflect.DeepEqual(res.StructuredContent.Paths, want) {
+				return fmt.Errorf("toml_paths: got %v, want %v", res.StructuredContent.Paths, want)
+			}
+			return nil
+		}},
+		{`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"tq_query","arguments":{"toml":"","unknown":true}}}`, func(r Response) error {
+			return failedWith(r, CodeInvalidParams)
+		}},
+		{`{"jsonrpc":"2.0","id":8,"method":"resources/list"}`, func(r Response) error {
+			return failedWith(r, CodeMethodNotFound)
+		}},
+		{`{"jsonrpc":"2.0","id":9,`, func(r Response) error {
+			return failedWith(r, CodeParseError)
+		}},
+		{`[{"jsonrpc":"2.0","id":10,"method":"ping"}]`, func(r Response) error {
+			return failedWith(r, CodeInvalidRequest)
+		}},
+		{`{"jsonrpc":"2.0","id":11,"method":"tools/call","params":{"name":"tq_run"}}`, func(r Response) error {
+			return failedWith(r, CodeInvalidParams)
+		}},
+	}
+
+	var in, out bytes.Buffer
+	var checks []func(Response) error
+	for _, x := range script {
+		in.WriteString(x.request + "\n")
+		if x.check != nil {
+			checks = append(checks, x.check)
+		}
+	}
+	if err := s.Serve(context.Background(), &in, &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != len(checks) {
+		t.Fatalf("got %d responses, want %d", len(lines), len(checks))
+	}
+	for i, line := range lines {
+		var r Response
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Errorf("response %d: %v", i, err)
+			continue
+		}
+		if err := checks[i](r); err != nil {
+			t.Errorf("response %d: %v", i, err)
+		}
+	}
+}
+
+func TestServeCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}` + "\n")
+	var out bytes.Buffer
+	if err := New("").Serve(ctx, in, &out); err != context.Canceled || out.Len() > 0 {
+		t.Errorf("Serve() = %v with %q, want context.Canceled and no output", err, out.String())
+	}
+}
+
+func TestServeMessageTooLarge(t *testing.T) {
+	in := strings.NewReader(strings.Repeat(" ", MaxMessageSize+1))
+	if err := New("").Serve(context.Background(), in, &bytes.Buffer{}); err == nil {
+		t.Error("Serve() succeeded, want ErrMessageTooLarge")
+	}
+}
+
+func TestListPaths(t *testing.T) {
+	input := "\"k'\\\"\" = 1\n'\"quoted\"' = 2\n\"back\\\\slash\" = 3\n'say \"hi\" now' = 4\n" +
+		"[t]\n\"it's\" = [1, {a = 5}]\nempty = []\n"
+	raw, _ := json.Marshal(map[string]string{"toml": input})
+	res, err := listPaths(context.Background(), raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.IsError {
+		t.Fatalf("toml_paths failed: %+v", res)
+	}
+	out := res.StructuredContent.(Paths)
+	wantSkipped := []Skipped{{Path: ".", Key: `"quoted"`}, {Path: ".", Key: `k'"`}}
+	if !reflect.DeepEqual(out.Skipped, wantSkipped) {
+		t.Errorf("skipped %v, want %v", out.Skipped, wantSkipped)
+	}
+	doc, err := eval.Decode(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Paths) != 5 {
+		t.Errorf("got paths %v, want 5", out.Paths)
+	}
+	for _, p := range out.Paths {
+		values, err := doc.Values(p.Path, eval.Options{})
+		if err != nil {
+			t.Errorf("%s: %v", p.Path, err)
+			continue
+		}
+		if len(values) != 1 || tomlType(values[0]) != p.Type {
+			t.Errorf("%s selects %v, want a single %s", p.Path, values, p.Type)
+		}
+	}
+}
+
+func TestToolDescriptionsHaveValidQueries(t *testing.T) {
+	for _, tool := range Tools() {
+		for name, prop := range tool.InputSchema.Properties {
+			_, example, ok := strings.Cut(prop.Description, "for example ")
+			if !ok {
+				continue
+			}
+			example = strings.TrimSuffix(example, ".")
+			if err := eval.Validate(example); err != nil {
+				t.Errorf("%s %s: example %s: %v", tool.Name, name, example, err)
+			}
+		}
+	}
+}
+
+// result decodes the result of a successful response.
+func result(r Response, v any) error {
+	if r.Error != nil {
+		return r.Error
+	}
+	b, err := json.Marshal(r.Result)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// diagnosed checks that the tool call failed with a single diagnostic from
+// the source at the position.
+func diagnosed(r Response, tool string, want Diagnostic) error {
+	var res struct {
+		StructuredContent Diagnostics `json:"structuredContent"`
+		IsError           bool        `json:"isError"`
+	}
+	if err := result(r, &res); err != nil {
+		return err
+	}
+	ds := res.StructuredContent.Diagnostics
+	if !res.IsError || len(ds) != 1 || ds[0].Source != want.Source ||
+		ds[0].Line != want.Line || ds[0].Column != want.Column || ds[0].Explanation == "" {
+		return fmt.Errorf("%s: got %+v", tool, res)
+	}
+	return nil
+}
+
+// failedWith checks that the request failed with the JSON-RPC error code.
+func failedWith(r Response, code int) error {
+	if r.Error == nil || r.Error.Code != code {
+		return fmt.Errorf("want error %d, got %+v", code, r)
+	}
+	return nil
+}
@@ -0,0 +1,395 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	gotoml "github.com/pelletier/go-toml/v2"
+
+	"github.com/mdm-code/tqweb/server/diff"
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/mdm-code/tqweb/server/i18n"
+	"github.com/mdm-code/tqweb/server/openapi"
+)
+
+// Tool is a tool the client can call along with the JSON Schema of its
+// arguments.
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema *openapi.Schema `json:"inputSchema"`
+	call        func(ctx context.Context, args json.RawMessage) (*CallResult, error)
+}
+
+// CallResult is the result of a tool call. The structured content is given
+// as JSON text as well for clients that only read the text content. Errors
+// caused by the arguments are reported with IsError set and the diagnostics
+// in the structured content rather than as JSON-RPC errors, so that the
+// model calling the tool gets to see them.
+type CallResult struct {
+	Content           []Content `json:"content"`
+	StructuredContent any       `json:"structuredContent,omitempty"`
+	IsError           bool      `json:"isError,omitempty"`
+}
+
+// Content is a block of the content of a tool result.
+type Content struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Diagnostic describes an error in a tq query or a TOML document. Columns of
+// tq queries and lines and columns of TOML documents count from one and are
+// left out when they are not known.
+type Diagnostic struct {
+	Source      string `json:"source"`
+	Name        string `json:"name,omitempty"`
+	Query       string `json:"query,omitempty"`
+	Message     string `json:"message"`
+	Detail      string `json:"detail"`
+	Explanation string `json:"explanation,omitempty"`
+	Line        int    `json:"line,omitempty"`
+	Column      int    `json:"column,omitempty"`
+}
+
+// Diagnostics is the structured content of failed tool calls.
+type Diagnostics struct {
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// QueryArguments are the arguments of the tq_query tool.
+type QueryArguments struct {
+	Query   string       `json:"query"`
+	Queries []eval.Query `json:"queries"`
+	TOML    string       `json:"toml"`
+	Options eval.Options `json:"options"`
+}
+
+// QueryResults is the structured content of the tq_query tool.
+type QueryResults struct {
+	Results     []eval.Result `json:"results"`
+	Diagnostics []Diagnostic  `json:"diagnostics,omitempty"`
+}
+
+// Validity is the structured content of the validation tools.
+type Validity struct {
+	Valid bool `json:"valid"`
+}
+
+// Path is a leaf of a TOML document along with the tq query selecting it.
+type Path struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+}
+
+// Skipped is a key no tq query can select, along with the path of its table.
+// tq strings have no escapes and lose the quotes around them, so keys with
+// both kinds of quotes, quotes at either end or line breaks are out of reach.
+type Skipped struct {
+	Path string `json:"path"`
+	Key  string `json:"key"`
+}
+
+// Paths is the structured content of the toml_paths tool.
+type Paths struct {
+	Paths   []Path    `json:"paths"`
+	Skipped []Skipped `json:"skipped,omitempty"`
+}
+
+// Tools returns the tools offered by the server.
+func Tools() []Tool {
+	return []Tool{
+		{
+			Name: "tq_query",
+			Description: "Run tq queries against a TOML document and return the " +
+				"TOML output of each query. Queries run independently, so a " +
+				"failing query does not affect the others.",
+			InputSchema: object(map[string]*openapi.Schema{
+				"query":   str(`The tq query to run, for example ["servers"][0]["host"].`),
+				"queries": queriesSchema(),
+				"toml":    str("The TOML document to query."),
+				"options": optionsSchema(),
+			}, "toml"),
+			call: runQueries,
+		},
+		{
+			Name:        "tq_validate",
+			Description: "Check whether a tq query is valid without running it.",
+			InputSchema: object(map[string]*openapi.Schema{
+				"query": str("The tq query to check."),
+			}, "query"),
+			call: validateQuery,
+		},
+		{
+			Name:        "toml_validate",
+			Description: "Check whether a TOML document is valid.",
+			InputSchema: object(map[string]*openapi.Schema{
+				"toml": str("The TOML document to check."),
+			}, "toml"),
+			call: validateTOML,
+		},
+		{
+			Name: "toml_paths",
+			Description: "List every leaf value of a TOML document as the tq " +
+				"query selecting it along with the TOML type of the value. " +
+				"Empty tables and arrays are listed as leaves. Keys no tq " +
+				"query can select are listed as skipped.",
+			InputSchema: object(map[string]*openapi.Schema{
+				"toml": str("The TOML document to list the paths of."),
+			}, "toml"),
+			call: listPaths,
+		},
+	}
+}
+
+func runQueries(ctx context.Context, raw json.RawMessage) (*CallResult, error) {
+	var args QueryArguments
+	if err := arguments(raw, &args); err != nil {
+		return nil, err
+	}
+	queries := args.Queries
+	if args.Query != "" {
+		queries = append([]eval.Query{{Query: args.Query}}, queries...)
+	}
+	if len(queries) == 0 {
+		return nil, &Error{Code: CodeInvalidParams, Message: "Invalid params", Data: "no query given"}
+	}
+	doc, err := eval.DecodeContext(ctx, args.TOML)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return failed(tomlDiagnostic(ctx, err))
+	}
+	results, err := doc.RunQueriesContext(ctx, queries, args.Options)
+	if err != nil {
+		return nil, err
+	}
+	out := QueryResults{Results: results}
+	for _, r := range results {
+		if r.Error != "" {
+			d := queryDiagnostic(ctx, r.Query, r.Error)
+			d.Name = r.Name
+			out.Diagnostics = append(out.Diagnostics, d)
+		}
+	}
+	return structured(out, len(out.Diagnostics) > 0)
+}
+
+func validateQuery(ctx context.Context, raw json.RawMessage) (*CallResult, error) {
+	var args struct {
+		Query string `json:"query"`
+	}
+	if err := arguments(raw, &args); err != nil {
+		return nil, err
+	}
+	if err := eval.Validate(args.Query); err != nil {
+		return failed(queryDiagnostic(ctx, args.Query, err.Error()))
+	}
+	return structured(Validity{Valid: true}, false)
+}
+
+func validateTOML(ctx context.Context, raw json.RawMessage) (*CallResult, error) {
+	var args struct {
+		TOML string `json:"toml"`
+	}
+	if err := arguments(raw, &args); err != nil {
+		return nil, err
+	}
+	if _, err := eval.DecodeContext(ctx, args.TOML); err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return failed(tomlDiagnostic(ctx, err))
+	}
+	return structured(Validity{Valid: true}, false)
+}
+
+func listPaths(ctx context.Context, raw json.RawMessage) (*CallResult, error) {
+	var args struct {
+		TOML string `json:"toml"`
+	}
+	if err := arguments(raw, &args); err != nil {
+		return nil, err
+	}
+	doc, err := eval.DecodeContext(ctx, args.TOML)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return failed(tomlDiagnostic(ctx, err))
+	}
+	out := Paths{Paths: []Path{}}
+	leaves("", doc.Data(), &out)
+	return structured(out, false)
+}
+
+// leaves lists the leaves under the path in document order for arrays and in
+// sorted order for tables.
+func leaves(path string, v any, out *Paths) {
+	switch v := v.(type) {
+	case map[string]any:
+		if len(v) > 0 || path == "" {
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			slices.Sort(keys)
+			for _, k := range keys {
+				f, ok := diff.KeyFilter(k)
+				if !ok {
+					out.Skipped = append(out.Skipped, Skipped{Path: root(path), Key: k})
+					continue
+				}
+				leaves(path+f, v[k], out)
+			}
+			return
+		}
+	case []any:
+		if len(v) > 0 {
+			for i, e := range v {
+				leaves(path+diff.Index(i), e, out)
+			}
+			return
+		}
+	}
+	out.Paths = append(out.Paths, Path{Path: path, Type: tomlType(v)})
+}
+
+// root names the root of the document in paths.
+func root(path string) string {
+	if path == "" {
+		return "."
+	}
+	return path
+}
+
+// tomlType names the TOML type of the decoded value.
+func tomlType(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "table"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case int64:
+		return "integer"
+	case float64:
+		return "float"
+	case bool:
+		return "boolean"
+	case time.Time:
+		return "offset-date-time"
+	case gotoml.LocalDateTime:
+		return "local-date-time"
+	case gotoml.LocalDate:
+		return "local-date"
+	case gotoml.LocalTime:
+		return "local-time"
+	}
+	return "unknown"
+}
+
+// queryDiagnostic describes the tq error. tq points at the offending
+// character of the query with a caret on the line below it.
+func queryDiagnostic(ctx context.Context, query, detail string) Diagnostic {
+	d := Diagnostic{
+		Source:      "tq",
+		Query:       query,
+		Message:     lastLine(detail),
+		Detail:      detail,
+		Explanation: i18n.Explain(i18n.FromContext(ctx), detail),
+	}
+	lines := strings.Split(detail, "\n")
+	if len(lines) >= 3 && strings.TrimSpace(lines[1]) == "^" {
+		d.Column = strings.IndexByte(lines[1], '^') + 1
+	}
+	return d
+}
+
+// tomlDiagnostic describes the error decoding a TOML document.
+func tomlDiagnostic(ctx context.Context, err error) Diagnostic {
+	detail := err.Error()
+	d := Diagnostic{
+		Source:      "toml",
+		Message:     lastLine(detail),
+		Detail:      detail,
+		Explanation: i18n.Explain(i18n.FromContext(ctx), detail),
+	}
+	var de *gotoml.DecodeError
+	if errors.As(err, &de) {
+		d.Line, d.Column = de.Position()
+	}
+	return d
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	return s[strings.LastIndexByte(s, '\n')+1:]
+}
+
+// arguments decodes the arguments of a tool call rejecting unknown ones.
+func arguments(raw json.RawMessage, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return &Error{Code: CodeInvalidParams, Message: "Invalid params", Data: err.Error()}
+	}
+	return nil
+}
+
+func failed(d ...Diagnostic) (*CallResult, error) {
+	return structured(Diagnostics{Diagnostics: d}, true)
+}
+
+func structured(v any, isError bool) (*CallResult, error) {
+	text, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return &CallResult{
+		Content:           []Content{{Type: "text", Text: string(text)}},
+		StructuredContent: v,
+		IsError:           isError,
+	}, nil
+}
+
+func object(properties map[string]*openapi.Schema, required ...string) *openapi.Schema {
+	return &openapi.Schema{Type: "object", Properties: properties, Required: required}
+}
+
+func str(description string) *openapi.Schema {
+	return &openapi.Schema{Type: "string", Description: description}
+}
+
+func boolean(description string) *openapi.Schema {
+	return &openapi.Schema{Type: "boolean", Description: description}
+}
+
+func queriesSchema() *openapi.Schema {
+	return &openapi.Schema{
+		Type:        "array",
+		Description: "Named tq queries to run after the query, if one is given.",
+		Items: object(map[string]*openapi.Schema{
+			"name":  str("The name of the query, repeated in its result."),
+			"query": str("The tq query to run."),
+		}, "query"),
+	}
+}
+
+func optionsSchema() *openapi.Schema {
+	s := object(map[string]*openapi.Schema{
+		"tablesInline":    boolean("Write tables inline."),
+		"arraysMultiline": boolean("Write arrays on multiple lines."),
+		"indentSymbol":    str("The indentation string, two spaces by default."),
+		"indentTables":    boolean("Indent nested tables."),
+	})
+	s.Description = "The output flags of tq."
+	return s
+}
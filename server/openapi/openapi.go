@@ -0,0 +1,314 @@
+/*
+Package openapi describes HTTP APIs with OpenAPI 3 documents. The schemas of
+request and response bodies are derived from the Go types the handlers bind
+and return, so they follow the JSON encoding of those types, and a document
+can be verified against the routes registered with the HTTP server.
+*/
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Version is the version of the OpenAPI specification the documents follow.
+const Version = "3.0.3"
+
+// ErrMismatch is returned when the registered routes and the document
+// describing them disagree.
+var ErrMismatch = errors.New("routes do not match the API specification")
+
+// Document is the root object of an OpenAPI document.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem holds the operations of a path by their lowercase HTTP method.
+type PathItem map[string]*Operation
+
+// Operation describes a single API operation on a path.
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary"`
+	Description string              `json:"description,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+// Parameter describes a path, query or header parameter of an operation.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the request bodies an operation accepts by their
+// media type.
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// MediaType holds the schema of a body of one media type.
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Response describes a response of an operation.
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Header describes a response header.
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// Components holds the schemas the operations refer to by name.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Schema is the subset of the OpenAPI schema object the documents use.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Endpoint is an operation along with its method and path.
+type Endpoint struct {
+	Method string
+	Path   string
+	*Operation
+}
+
+// New returns an empty document describing the API.
+func New(info Info) *Document {
+	return &Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Paths:      make(map[string]PathItem),
+		Components: Components{Schemas: make(map[string]*Schema)},
+	}
+}
+
+// Add adds the operation under the method and the path. The path parameters
+// are written in braces, as in /snippets/{id}.
+func (d *Document) Add(method, path string, op Operation) {
+	item, ok := d.Paths[path]
+	if !ok {
+		item = make(PathItem)
+		d.Paths[path] = item
+	}
+	item[strings.ToLower(method)] = &op
+}
+
+// Endpoints returns the operations of the document ordered by path and
+// method.
+func (d *Document) Endpoints() []Endpoint {
+	var endpoints []Endpoint
+	for path, item := range d.Paths {
+		for method, op := range item {
+			endpoints = append(endpoints, Endpoint{strings.ToUpper(method), path, op})
+		}
+	}
+	slices.SortFunc(endpoints, func(a, b Endpoint) int {
+		if c := strings.Compare(a.Path, b.Path); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Method, b.Method)
+	})
+	return endpoints
+}
+
+// SchemaNames returns the sorted names of the component schemas.
+func (d *Document) SchemaNames() []string {
+	var names []string
+	for name := range d.Components.Schemas {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+var (
+	timeType        = reflect.TypeFor[time.Time]()
+	rawMessageType  = reflect.TypeFor[json.RawMessage]()
+	marshalerType   = reflect.TypeFor[json.Marshaler]()
+	textMarshalType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// SchemaOf returns the schema of the JSON encoding of the value. Named
+// struct types are added to the component schemas and referred to by name.
+// Types with their own JSON encoding are described as any value.
+func (d *Document) SchemaOf(v any) *Schema {
+	return d.schemaOf(reflect.TypeOf(v))
+}
+
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{}
+	case t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType):
+		return &Schema{}
+	case t.Implements(textMarshalType):
+		return &Schema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.object(t)
+		}
+		name := schemaName(t)
+		if _, ok := d.Components.Schemas[name]; !ok {
+			// The placeholder stops recursive types from recursing forever.
+			d.Components.Schemas[name] = &Schema{}
+			d.Components.Schemas[name] = d.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+// object describes a struct type as an object with the properties of its
+// JSON encoding. Fields without omitempty are required.
+func (d *Document) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	d.fields(t, s)
+	return s
+}
+
+func (d *Document) fields(t reflect.Type, s *Schema) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			d.fields(f.Type, s)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = d.schemaOf(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// schemaName names the component schema of the type after the type and its
+// package, unless the type name starts with the package name already.
+func schemaName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+	if pkg == "route" || strings.HasPrefix(strings.ToLower(t.Name()), pkg) {
+		return t.Name()
+	}
+	return strings.ToUpper(pkg[:1]) + pkg[1:] + t.Name()
+}
+
+// Verify checks the document against the routes registered under the path
+// prefix. Every route must have its operation in the document with all of
+// its path parameters described, and every operation of the document under
+// the prefix must have its route.
+func (d *Document) Verify(routes []*echo.Route, prefix string) error {
+	var errs []error
+	registered := make(map[string]bool)
+	for _, r := range routes {
+		if !strings.HasPrefix(r.Path, prefix) {
+			continue
+		}
+		path, params := specPath(r.Path)
+		registered[r.Method+" "+path] = true
+		op := d.Paths[path][strings.ToLower(r.Method)]
+		if op == nil {
+			errs = append(errs, fmt.Errorf("%w: %s %s is not described", ErrMismatch, r.Method, path))
+			continue
+		}
+		for _, p := range params {
+			if !slices.ContainsFunc(op.Parameters, func(q Parameter) bool {
+				return q.In == "path" && q.Name == p && q.Required
+			}) {
+				errs = append(errs, fmt.Errorf("%w: %s %s lacks the path parameter %s", ErrMismatch, r.Method, path, p))
+			}
+		}
+	}
+	for _, e := range d.Endpoints() {
+		if strings.HasPrefix(e.Path, prefix) && !registered[e.Method+" "+e.Path] {
+			errs = append(errs, fmt.Errorf("%w: %s %s is not registered", ErrMismatch, e.Method, e.Path))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// specPath turns the path of an echo route into the path of an OpenAPI
+// document and returns the names of its parameters.
+func specPath(path string) (string, []string) {
+	var params []string
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		if name, ok := strings.CutPrefix(p, ":"); ok {
+			params = append(params, name)
+			parts[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(parts, "/"), params
+}
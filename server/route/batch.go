@@ -0,0 +1,122 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mdm-code/tqweb/server/batch"
+	"github.com/mdm-code/tqweb/server/component"
+)
+
+var batchContentTypes = map[string]string{
+	"ndjson": "application/x-ndjson",
+	"json":   echo.MIMEApplicationJSON,
+	"csv":    "text/csv",
+}
+
+// RegisterBatchRoutes groups the batch evaluation routes.
+func RegisterBatchRoutes(e *echo.Echo) *echo.Echo {
+	e.GET("/batch", BatchPage)
+	e.POST("/batch", BatchPage)
+	e.POST("/api/v1/batch", ProcessBatch)
+	return e
+}
+
+// BatchPage renders the batch form and, once submitted, the results grouped
+// per file. Results requested as JSON or CSV are sent as a download instead.
+func BatchPage(c echo.Context) error {
+	if c.Request().Method == http.MethodGet {
+		page := component.Batch("", nil)
+		return page.Render(c.Request().Context(), c.Response().Writer)
+	}
+	format := c.FormValue("format")
+	if _, ok := batchContentTypes[format]; ok {
+		c.Response().Header().Set(echo.HeaderContentDisposition, "attachment; filename=tqweb-batch."+format)
+		return streamBatch(c, format)
+	}
+	b, err := readBatch(c)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+	results := []batch.Result{}
+	query := c.FormValue("tqQuery")
+	err = b.Run(query, FormOptions(c), func(r batch.Result) error {
+		results = append(results, r)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	page := component.Batch(query, results)
+	return page.Render(c.Request().Context(), c.Response().Writer)
+}
+
+// ProcessBatch runs the tq query over every uploaded TOML document and streams
+// the results one file at a time. The format query parameter selects between
+// ndjson, the default, json and csv.
+func ProcessBatch(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		format = "ndjson"
+	}
+	return streamBatch(c, format)
+}
+
+func streamBatch(c echo.Context, format string) error {
+	contentType, ok := batchContentTypes[format]
+	if !ok {
+		return &echo.HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: "Bad request",
+		}
+	}
+	b, err := readBatch(c)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+	res := c.Response()
+	w, _ := batch.NewWriter(format, res)
+	res.Header().Set(echo.HeaderContentType, contentType)
+	res.WriteHeader(http.StatusOK)
+	err = b.Run(c.FormValue("tqQuery"), FormOptions(c), func(r batch.Result) error {
+		if err := w.Write(r); err != nil {
+			return err
+		}
+		res.Flush()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func readBatch(c echo.Context) (*batch.Batch, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, &echo.HTTPError{
+			Code:     http.StatusBadRequest,
+			Message:  "Bad request",
+			Internal: err,
+		}
+	}
+	b, err := batch.FromMultipart(form.File["files"])
+	if errors.Is(err, batch.ErrTooManyEntries) {
+		return nil, &echo.HTTPError{
+			Code:     http.StatusRequestEntityTooLarge,
+			Message:  "Request entity too large",
+			Internal: err,
+		}
+	}
+	if err != nil {
+		return nil, &echo.HTTPError{
+			Code:     http.StatusUnprocessableEntity,
+			Message:  "Unprocessable entity",
+			Internal: err,
+		}
+	}
+	return b, nil
+}
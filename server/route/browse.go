@@ -0,0 +1,164 @@
+package route
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mdm-code/tqweb/server/batch"
+	"github.com/mdm-code/tqweb/server/browse"
+	"github.com/mdm-code/tqweb/server/component"
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/mdm-code/tqweb/server/watch"
+)
+
+const heartbeatInterval = 15 * time.Second
+
+// BrowseRoutes returns the registration of the routes exploring the TOML files
+// of the tree.
+func BrowseRoutes(t *browse.Tree) func(*echo.Echo) *echo.Echo {
+	return func(e *echo.Echo) *echo.Echo {
+		h := browseHandler{tree: t, hub: watch.NewHub(watch.DefaultInterval)}
+		e.GET("/browse", h.List)
+		e.POST("/browse", h.Search)
+		e.GET("/browse/open", h.Open)
+		e.GET("/browse/watch", h.Watch)
+		e.GET("/browse/watch/events", h.WatchEvents)
+		return e
+	}
+}
+
+type browseHandler struct {
+	tree *browse.Tree
+	hub  *watch.Hub
+}
+
+// List lists the TOML files of the tree.
+func (h browseHandler) List(c echo.Context) error {
+	return h.render(c, "", nil)
+}
+
+// Search runs the tq query across every TOML file of the tree and renders the
+// matches grouped by file path.
+func (h browseHandler) Search(c echo.Context) error {
+	b, err := h.tree.Batch()
+	if err != nil {
+		return err
+	}
+	query := c.FormValue("tqQuery")
+	results := []batch.Result{}
+	err = b.Run(query, FormOptions(c), func(r batch.Result) error {
+		results = append(results, r)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return h.render(c, query, results)
+}
+
+// Open opens the TOML file of the tree in the playground.
+func (h browseHandler) Open(c echo.Context) error {
+	input, err := h.tree.Read(c.QueryParam("path"))
+	if err != nil {
+		return &echo.HTTPError{
+			Code:     http.StatusNotFound,
+			Message:  "Not found",
+			Internal: err,
+		}
+	}
+	index := component.Index(component.Playground{Input: input})
+	return index.Render(c.Request().Context(), c.Response().Writer)
+}
+
+// Watch shows the results of the query re-run whenever the TOML file of the
+// tree changes.
+func (h browseHandler) Watch(c echo.Context) error {
+	path := c.QueryParam("path")
+	if _, err := h.tree.Resolve(path); err != nil {
+		return &echo.HTTPError{
+			Code:     http.StatusNotFound,
+			Message:  "Not found",
+			Internal: err,
+		}
+	}
+	events := "/browse/watch/events?" + c.QueryString()
+	page := component.Watch(path, c.QueryParam("tqQuery"), events)
+	return page.Render(c.Request().Context(), c.Response().Writer)
+}
+
+// WatchEvents streams the results of the query re-run on every change of the
+// TOML file as Server-Sent Events named result. Comments are sent as
+// heartbeats to keep idle connections open.
+func (h browseHandler) WatchEvents(c echo.Context) error {
+	path, err := h.tree.Resolve(c.QueryParam("path"))
+	if err != nil {
+		return &echo.HTTPError{
+			Code:     http.StatusNotFound,
+			Message:  "Not found",
+			Internal: err,
+		}
+	}
+	results, cancel := h.hub.Subscribe(path, c.QueryParam("tqQuery"), FormOptions(c))
+	defer cancel()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	ctx := c.Request().Context()
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case r, ok := <-results:
+			if !ok {
+				return nil
+			}
+			var buf bytes.Buffer
+			result := component.Result(0, eval.Result{Output: r.Output, Error: r.Error})
+			if err := result.Render(ctx, &buf); err != nil {
+				return err
+			}
+			if err := writeEvent(res, "result", buf.String()); err != nil {
+				return nil
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(res, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+		}
+		res.Flush()
+	}
+}
+
+// writeEvent writes a Server-Sent Event splitting multi-line data into
+// separate data fields.
+func writeEvent(w io.Writer, event, data string) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "event: %s\n", event)
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&buf, "data: %s\n", line)
+	}
+	buf.WriteString("\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (h browseHandler) render(c echo.Context, query string, results []batch.Result) error {
+	files, err := h.tree.Files()
+	if err != nil {
+		return err
+	}
+	page := component.Browse(h.tree.Root(), files, query, results)
+	return page.Render(c.Request().Context(), c.Response().Writer)
+}
@@ -0,0 +1,88 @@
+package route
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mdm-code/tqweb/server/cache"
+	"github.com/mdm-code/tqweb/server/i18n"
+)
+
+// CacheHeader set to bypass on a request skips the result cache. Responses
+// carry it set to hit, miss or bypass.
+const CacheHeader = "Tqweb-Cache"
+
+const resultsKey = "tqweb_results"
+
+// UseResults makes the routes cache the rendered query results in the cache.
+// The routes running queries expect it to be installed, as server.Server
+// does.
+func UseResults(results *cache.Cache) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(resultsKey, results)
+			return next(c)
+		}
+	}
+}
+
+func resultCache(c echo.Context) *cache.Cache {
+	return c.Get(resultsKey).(*cache.Cache)
+}
+
+// CacheStats responds with the result cache metrics.
+func CacheStats(c echo.Context) error {
+	return c.JSON(http.StatusOK, resultCache(c).Stats())
+}
+
+// htmlFormat names the HTML output format in cache keys. Rendered results are
+// translated, so the language of the request is a part of it.
+func htmlFormat(c echo.Context) string {
+	return "html:" + i18n.FromContext(c.Request().Context()).String()
+}
+
+// cached responds with the result cached under the key or renders it and
+// caches it. Responses carry an ETag, and requests whose If-None-Match header
+// matches it get 304 Not Modified without a body.
+func cached(c echo.Context, key cache.Key, render func() (cache.Entry, error)) error {
+	bypass := strings.EqualFold(c.Request().Header.Get(CacheHeader), "bypass")
+	status := "bypass"
+	var entry cache.Entry
+	var ok bool
+	if !bypass {
+		status = "miss"
+		if entry, ok = resultCache(c).Get(key); ok {
+			status = "hit"
+		}
+	}
+	if !ok {
+		var err error
+		if entry, err = render(); err != nil {
+			return err
+		}
+		if !bypass {
+			resultCache(c).Put(key, entry)
+		}
+	}
+	h := c.Response().Header()
+	h.Set(CacheHeader, status)
+	h.Set("ETag", entry.ETag)
+	if matchETag(c.Request().Header.Get("If-None-Match"), entry.ETag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return c.Blob(http.StatusOK, entry.ContentType, entry.Body)
+}
+
+// matchETag reports whether the If-None-Match header lists the ETag. Weak
+// validators match their strong counterparts as RFC 9110 prescribes for
+// If-None-Match.
+func matchETag(header, etag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == etag {
+			return true
+		}
+	}
+	return false
+}
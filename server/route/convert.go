@@ -0,0 +1,61 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mdm-code/tqweb/server/component"
+	"github.com/mdm-code/tqweb/server/convert"
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/mdm-code/tqweb/server/upload"
+)
+
+// ConvertRequest is the JSON body of a request converting a document between
+// TOML and JSON. The options apply to the TOML output only.
+type ConvertRequest struct {
+	Input   string       `json:"input"`
+	Options eval.Options `json:"options"`
+}
+
+// ConvertPage renders the two panes converting documents between TOML and
+// JSON.
+func ConvertPage(c echo.Context) error {
+	page := component.Convert()
+	return page.Render(c.Request().Context(), c.Response().Writer)
+}
+
+// Convert converts the input document between the formats given in the from
+// and to query parameters. Values that could not be converted are listed in
+// the response next to the converted document.
+func Convert(c echo.Context) error {
+	var req ConvertRequest
+	if isJSON(c) {
+		if err := (&echo.DefaultBinder{}).BindBody(c, &req); err != nil {
+			return &echo.HTTPError{
+				Code:     http.StatusBadRequest,
+				Message:  "Bad request",
+				Internal: err,
+			}
+		}
+	} else {
+		req = ConvertRequest{Input: c.FormValue("input"), Options: FormOptions(c)}
+	}
+	input, err := upload.Normalize([]byte(req.Input))
+	if err != nil {
+		return uploadError(err)
+	}
+	result, err := convert.Convert(c.QueryParam("from"), c.QueryParam("to"), input, req.Options)
+	if err != nil {
+		code, message := http.StatusUnprocessableEntity, "Unprocessable entity"
+		if errors.Is(err, convert.ErrFormat) {
+			code, message = http.StatusBadRequest, "Bad request"
+		}
+		return &echo.HTTPError{Code: code, Message: message, Internal: err}
+	}
+	if isJSON(c) {
+		return c.JSON(http.StatusOK, result)
+	}
+	converted := component.Converted(result)
+	return converted.Render(c.Request().Context(), c.Response().Writer)
+}
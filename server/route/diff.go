@@ -0,0 +1,73 @@
+package route
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mdm-code/tqweb/server/component"
+	"github.com/mdm-code/tqweb/server/diff"
+)
+
+// DiffRequest is the JSON body of a request comparing two TOML documents.
+type DiffRequest struct {
+	Left  string `json:"left"`
+	Right string `json:"right"`
+	Query string `json:"query"`
+}
+
+// DiffResponse is the JSON body of the response listing the differences.
+type DiffResponse struct {
+	Differences []diff.Difference `json:"differences"`
+}
+
+// RegisterDiffRoutes groups the TOML diff routes.
+func RegisterDiffRoutes(e *echo.Echo) *echo.Echo {
+	e.GET("/diff", DiffPage)
+	e.POST("/diff", Diff)
+	e.POST("/api/v1/diff", Diff)
+	return e
+}
+
+// DiffPage renders the form comparing two TOML documents.
+func DiffPage(c echo.Context) error {
+	page := component.Diff(component.DiffForm{})
+	return page.Render(c.Request().Context(), c.Response().Writer)
+}
+
+// Diff compares two TOML documents, optionally narrowed down by a tq query
+// applied to both of them. JSON requests get the list of differences as
+// JSON, form submissions get them rendered side by side.
+func Diff(c echo.Context) error {
+	var req DiffRequest
+	if isJSON(c) {
+		if err := (&echo.DefaultBinder{}).BindBody(c, &req); err != nil {
+			return &echo.HTTPError{
+				Code:     http.StatusBadRequest,
+				Message:  "Bad request",
+				Internal: err,
+			}
+		}
+	} else {
+		req = DiffRequest{
+			Left:  c.FormValue("left"),
+			Right: c.FormValue("right"),
+			Query: c.FormValue("tqQuery"),
+		}
+	}
+	diffs, err := diff.Documents(req.Left, req.Right, req.Query)
+	if err != nil {
+		return &echo.HTTPError{
+			Code:     http.StatusUnprocessableEntity,
+			Message:  "Unprocessable entity",
+			Internal: err,
+		}
+	}
+	if isJSON(c) {
+		if diffs == nil {
+			diffs = []diff.Difference{}
+		}
+		return c.JSON(http.StatusOK, DiffResponse{Differences: diffs})
+	}
+	differences := component.Differences(diffs)
+	return differences.Render(c.Request().Context(), c.Response().Writer)
+}
@@ -0,0 +1,140 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mdm-code/tqweb/server/docstore"
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/mdm-code/tqweb/server/upload"
+)
+
+const documentsKey = "tqweb_documents"
+
+// UseDocuments makes the routes keep the stored documents in the store. The
+// routes taking a document ID expect it to be installed, as server.Server
+// does.
+func UseDocuments(store *docstore.Store) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(documentsKey, store)
+			return next(c)
+		}
+	}
+}
+
+func documents(c echo.Context) *docstore.Store {
+	return c.Get(documentsKey).(*docstore.Store)
+}
+
+// DocumentRequest is the JSON body of a request storing a TOML document.
+type DocumentRequest struct {
+	TOMLData string `json:"tomlData"`
+}
+
+// RegisterDocumentRoutes groups the routes of the stored documents.
+func RegisterDocumentRoutes(e *echo.Echo) *echo.Echo {
+	g := e.Group("/api/v1/documents")
+	g.POST("", StoreDocument, LimitBody)
+	g.DELETE("/:id", DeleteDocument)
+	return e
+}
+
+// StoreDocument decodes the TOML document and stores it so that the query
+// endpoints can be given its ID in the documentId field in place of the
+// input. The document is read from the JSON body, the tomlFile upload or the
+// tomlData form field.
+func StoreDocument(c echo.Context) error {
+	var input string
+	if isJSON(c) {
+		var req DocumentRequest
+		if err := (&echo.DefaultBinder{}).BindBody(c, &req); err != nil {
+			return &echo.HTTPError{
+				Code:     http.StatusBadRequest,
+				Message:  "Bad request",
+				Internal: err,
+			}
+		}
+		var err error
+		if input, err = upload.Normalize([]byte(req.TOMLData)); err != nil {
+			return uploadError(err)
+		}
+	} else {
+		var err error
+		if input, err = TOMLInput(c); err != nil {
+			return err
+		}
+	}
+	doc, err := eval.Decode(input)
+	if err != nil {
+		return &echo.HTTPError{
+			Code:     http.StatusUnprocessableEntity,
+			Message:  "Unprocessable entity",
+			Internal: err,
+		}
+	}
+	info, err := documents(c).Put(doc)
+	if err != nil {
+		if errors.Is(err, docstore.ErrTooLarge) {
+			return &echo.HTTPError{
+				Code:     http.StatusRequestEntityTooLarge,
+				Message:  "Request entity too large",
+				Internal: err,
+			}
+		}
+		return err
+	}
+	return c.JSON(http.StatusCreated, info)
+}
+
+// DeleteDocument removes the stored document before it expires.
+func DeleteDocument(c echo.Context) error {
+	if err := documents(c).Delete(c.Param("id")); err != nil {
+		return &echo.HTTPError{
+			Code:     http.StatusNotFound,
+			Message:  "Not found",
+			Internal: err,
+		}
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// document returns the stored document when the ID is given and decodes the
+// input otherwise. All errors are returned as HTTP errors.
+func document(c echo.Context, id, input string) (eval.Document, error) {
+	if id != "" {
+		doc, err := documents(c).Get(id)
+		if err != nil {
+			return doc, &echo.HTTPError{
+				Code:     http.StatusNotFound,
+				Message:  "Not found",
+				Internal: err,
+			}
+		}
+		return doc, nil
+	}
+	doc, err := eval.Decode(input)
+	if err != nil {
+		return doc, &echo.HTTPError{
+			Code:     http.StatusUnprocessableEntity,
+			Message:  "Unprocessable entity",
+			Internal: err,
+		}
+	}
+	return doc, nil
+}
+
+// cacheInput returns the part of the cache key standing for the input. The
+// stored documents never change, so their IDs stand in for their contents.
+// The document must still be stored, so that the results cached for it are
+// not served once it is deleted or expires.
+func cacheInput(c echo.Context, id, input string) (string, error) {
+	if id == "" {
+		return input, nil
+	}
+	if _, err := document(c, id, ""); err != nil {
+		return "", err
+	}
+	return "documentId:" + id, nil
+}
@@ -0,0 +1,50 @@
+package route_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/mdm-code/tqweb/server"
+	"github.com/mdm-code/tqweb/server/docstore"
+	"github.com/mdm-code/tqweb/server/route"
+)
+
+func TestCachedResultsOfDeletedDocument(t *testing.T) {
+	c := newClient(server.Server())
+	rec := c.json(http.MethodPost, "/api/v1/documents", `{"tomlData": "a = 1\n"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("store: status %d", rec.Code)
+	}
+	var info docstore.Info
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	query := `{"queries": [{"query": "[\"a\"]"}], "documentId": "` + info.ID + `"}`
+	for _, want := range []string{"miss", "hit"} {
+		rec := c.json(http.MethodPost, "/api/v1/queries", query)
+		if rec.Code != http.StatusOK || rec.Header().Get(route.CacheHeader) != want {
+			t.Fatalf("query: status %d, cache %s, want %s", rec.Code, rec.Header().Get(route.CacheHeader), want)
+		}
+	}
+	if rec := c.do(http.MethodDelete, "/api/v1/documents/"+info.ID, "", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: status %d", rec.Code)
+	}
+	if rec := c.json(http.MethodPost, "/api/v1/queries", query); rec.Code != http.StatusNotFound {
+		t.Errorf("query after delete: status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestServersDoNotShareDocuments(t *testing.T) {
+	rec := newClient(server.Server()).json(http.MethodPost, "/api/v1/documents", `{"tomlData": "a = 1\n"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("store: status %d", rec.Code)
+	}
+	var info docstore.Info
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if rec := newClient(server.Server()).do(http.MethodDelete, "/api/v1/documents/"+info.ID, "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("another server: status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,181 @@
+package route
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mdm-code/tqweb/server/component"
+)
+
+const (
+	embedWidth  = 640
+	embedHeight = 420
+)
+
+const frameAncestorsKey = "tqweb_frame_ancestors"
+
+// UseFrameAncestors sets the sources of the frame-ancestors directive sent
+// with the embedded snippets. Only the server itself may frame them
+// otherwise.
+func UseFrameAncestors(sources []string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(frameAncestorsKey, sources)
+			return next(c)
+		}
+	}
+}
+
+func frameAncestors(c echo.Context) []string {
+	if sources, ok := c.Get(frameAncestorsKey).([]string); ok {
+		return sources
+	}
+	return []string{"'self'"}
+}
+
+// RegisterEmbedRoutes groups the routes embedding snippets in other sites.
+func RegisterEmbedRoutes(e *echo.Echo) *echo.Echo {
+	e.GET("/embed/:id", Embed)
+	e.GET("/oembed", OEmbed)
+	return e
+}
+
+// OEmbedResponse is the JSON body of an oEmbed response of the rich type.
+type OEmbedResponse struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	Title        string `json:"title,omitempty"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+// Embed renders the snippet as a page to be framed by the allowed sources.
+// The query and the input can be edited if the edit query parameter is true.
+func Embed(c echo.Context) error {
+	s, err := snippets(c).Get(c.Param("id"))
+	if err != nil {
+		return &echo.HTTPError{
+			Code:     http.StatusNotFound,
+			Message:  "Not found",
+			Internal: err,
+		}
+	}
+	c.Response().Header().Set(
+		echo.HeaderContentSecurityPolicy,
+		"frame-ancestors "+strings.Join(frameAncestors(c), " "),
+	)
+	page := c.Scheme() + "://" + c.Request().Host + c.Request().URL.RequestURI()
+	embed := component.Embed(s, c.QueryParam("edit") == "true", oembedURL(c, page))
+	return embed.Render(c.Request().Context(), c.Response().Writer)
+}
+
+// OEmbed describes the embed of the snippet under the URL given in the url
+// query parameter. Both the embed URLs and the playground URLs restoring a
+// snippet are understood. Only the JSON format is supported.
+func OEmbed(c echo.Context) error {
+	if f := c.QueryParam("format"); f != "" && f != "json" {
+		return &echo.HTTPError{
+			Code:    http.StatusNotImplemented,
+			Message: "Not implemented",
+		}
+	}
+	u, err := url.Parse(c.QueryParam("url"))
+	if err != nil {
+		return &echo.HTTPError{
+			Code:     http.StatusBadRequest,
+			Message:  "Bad request",
+			Internal: err,
+		}
+	}
+	width, height, err := embedSize(c)
+	if err != nil {
+		return &echo.HTTPError{
+			Code:     http.StatusBadRequest,
+			Message:  "Bad request",
+			Internal: err,
+		}
+	}
+	id, ok := embeddedSnippet(u)
+	if !ok || u.Host != c.Request().Host {
+		return &echo.HTTPError{
+			Code:    http.StatusNotFound,
+			Message: "Not found",
+		}
+	}
+	s, err := snippets(c).Get(id)
+	if err != nil {
+		return &echo.HTTPError{
+			Code:     http.StatusNotFound,
+			Message:  "Not found",
+			Internal: err,
+		}
+	}
+	base := c.Scheme() + "://" + c.Request().Host
+	src := base + "/embed/" + s.ID
+	if u.Query().Get("edit") == "true" {
+		src += "?edit=true"
+	}
+	title := s.DisplayTitle()
+	return c.JSON(http.StatusOK, OEmbedResponse{
+		Version:      "1.0",
+		Type:         "rich",
+		Title:        title,
+		ProviderName: "tqweb",
+		ProviderURL:  base + "/",
+		HTML: fmt.Sprintf(
+			`<iframe src="%s" width="%d" height="%d" title="%s" style="border: 0"></iframe>`,
+			html.EscapeString(src), width, height, html.EscapeString(title),
+		),
+		Width:  width,
+		Height: height,
+	})
+}
+
+// embeddedSnippet returns the ID of the snippet the URL embeds or restores.
+func embeddedSnippet(u *url.URL) (string, bool) {
+	if id, ok := strings.CutPrefix(u.Path, "/embed/"); ok {
+		return id, id != ""
+	}
+	if u.Path == "/" || u.Path == "" {
+		id := u.Query().Get("snippet")
+		return id, id != ""
+	}
+	return "", false
+}
+
+// embedSize returns the size of the embed fitting into the maxwidth and
+// maxheight query parameters.
+func embedSize(c echo.Context) (int, int, error) {
+	width, height := embedWidth, embedHeight
+	for _, p := range []struct {
+		name string
+		size *int
+	}{
+		{"maxwidth", &width},
+		{"maxheight", &height},
+	} {
+		v := c.QueryParam(p.name)
+		if v == "" {
+			continue
+		}
+		max, err := strconv.Atoi(v)
+		if err != nil || max <= 0 {
+			return 0, 0, fmt.Errorf("invalid %s %q", p.name, v)
+		}
+		*p.size = min(*p.size, max)
+	}
+	return width, height, nil
+}
+
+// oembedURL returns the URL of the oEmbed endpoint describing the page.
+func oembedURL(c echo.Context, page string) string {
+	return c.Scheme() + "://" + c.Request().Host + "/oembed?" + url.Values{"url": {page}}.Encode()
+}
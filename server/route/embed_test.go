@@ -0,0 +1,53 @@
+package route_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mdm-code/tqweb/server"
+	"github.com/mdm-code/tqweb/server/route"
+)
+
+func TestEmbed(t *testing.T) {
+	c := newClient(server.Server())
+	form := url.Values{"tqQuery": {"."}, "tomlData": {"a = 1\n"}, "indentSymbol": {"----"}}
+	rec := c.form(http.MethodPost, "/api/v1/snippets", form)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("save: status %d", rec.Code)
+	}
+	var s struct{ ID string }
+	if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = c.get("/embed/" + s.ID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("embed: status %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `<script src="/assets/js/tqweb.js">`) {
+		t.Error("the embed does not load tqweb.js swapping in the error fragments")
+	}
+	if !strings.Contains(rec.Body.String(), `<input type="hidden" name="indentSymbol" value="----">`) {
+		t.Error("the embed does not run the snippet with its indent symbol")
+	}
+	want := "tqweb snippet " + s.ID
+	if !strings.Contains(rec.Body.String(), "<title>"+want+"</title>") {
+		t.Errorf("embed: title is not %q", want)
+	}
+
+	target := "/oembed?" + url.Values{"url": {"http://example.com/embed/" + s.ID}}.Encode()
+	rec = c.get(target)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("oembed: status %d", rec.Code)
+	}
+	var o route.OEmbedResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &o); err != nil {
+		t.Fatal(err)
+	}
+	if o.Title != want || !strings.Contains(o.HTML, `title="`+want+`"`) {
+		t.Errorf("oembed: title %q, html %s, want %q in both", o.Title, o.HTML, want)
+	}
+}
@@ -0,0 +1,23 @@
+package route
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/mdm-code/tqweb/server/component"
+	"github.com/mdm-code/tqweb/server/example"
+)
+
+// RegisterExampleRoutes groups the example gallery routes.
+func RegisterExampleRoutes(e *echo.Echo) *echo.Echo {
+	e.GET("/examples", Examples)
+	return e
+}
+
+// Examples lists the curated gallery of tq queries.
+func Examples(c echo.Context) error {
+	all, err := example.All()
+	if err != nil {
+		return err
+	}
+	examples := component.Examples(all)
+	return examples.Render(c.Request().Context(), c.Response().Writer)
+}
@@ -0,0 +1,63 @@
+package route
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mdm-code/tqweb/server/component"
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/mdm-code/tqweb/server/format"
+	"github.com/mdm-code/tqweb/server/upload"
+)
+
+// FormatRequest is the JSON body of a request normalizing a TOML document.
+type FormatRequest struct {
+	TOMLData string       `json:"tomlData"`
+	Options  eval.Options `json:"options"`
+}
+
+// FormatPage renders the form normalizing a TOML document.
+func FormatPage(c echo.Context) error {
+	page := component.Format()
+	return page.Render(c.Request().Context(), c.Response().Writer)
+}
+
+// FormatTOML decodes the TOML document and encodes it back with the requested
+// encoder options. The response lists the features of the source lost in the
+// round trip next to the normalized document.
+func FormatTOML(c echo.Context) error {
+	var req FormatRequest
+	if isJSON(c) {
+		if err := (&echo.DefaultBinder{}).BindBody(c, &req); err != nil {
+			return &echo.HTTPError{
+				Code:     http.StatusBadRequest,
+				Message:  "Bad request",
+				Internal: err,
+			}
+		}
+		input, err := upload.Normalize([]byte(req.TOMLData))
+		if err != nil {
+			return uploadError(err)
+		}
+		req.TOMLData = input
+	} else {
+		input, err := TOMLInput(c)
+		if err != nil {
+			return err
+		}
+		req = FormatRequest{TOMLData: input, Options: FormOptions(c)}
+	}
+	result, err := format.Format(req.TOMLData, req.Options)
+	if err != nil {
+		return &echo.HTTPError{
+			Code:     http.StatusUnprocessableEntity,
+			Message:  "Unprocessable entity",
+			Internal: err,
+		}
+	}
+	if isJSON(c) {
+		return c.JSON(http.StatusOK, result)
+	}
+	formatted := component.Formatted(result)
+	return formatted.Render(c.Request().Context(), c.Response().Writer)
+}
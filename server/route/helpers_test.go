@@ -0,0 +1,52 @@
+package route_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// client sends requests to the server carrying the headers of the client
+// and the cookies the server sets.
+type client struct {
+	e       *echo.Echo
+	header  http.Header
+	cookies []*http.Cookie
+}
+
+func newClient(e *echo.Echo) *client {
+	return &client{e: e, header: make(http.Header)}
+}
+
+// do sends the request with the body of the content type, if there is one.
+func (c *client) do(method, target, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	if contentType != "" {
+		req.Header.Set(echo.HeaderContentType, contentType)
+	}
+	for _, ck := range c.cookies {
+		req.AddCookie(ck)
+	}
+	rec := httptest.NewRecorder()
+	c.e.ServeHTTP(rec, req)
+	c.cookies = append(c.cookies, rec.Result().Cookies()...)
+	return rec
+}
+
+func (c *client) get(target string) *httptest.ResponseRecorder {
+	return c.do(http.MethodGet, target, "", "")
+}
+
+func (c *client) form(method, target string, form url.Values) *httptest.ResponseRecorder {
+	return c.do(method, target, echo.MIMEApplicationForm, form.Encode())
+}
+
+func (c *client) json(method, target, body string) *httptest.ResponseRecorder {
+	return c.do(method, target, echo.MIMEApplicationJSON, body)
+}
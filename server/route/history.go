@@ -0,0 +1,143 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mdm-code/tqweb/server/component"
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/mdm-code/tqweb/server/history"
+	"github.com/mdm-code/tqweb/server/snippet"
+)
+
+const (
+	historyCookie = "tqweb_history"
+	historyOff    = "off"
+)
+
+// RegisterHistoryRoutes groups the query history routes.
+func RegisterHistoryRoutes(e *echo.Echo) *echo.Echo {
+	e.GET("/history", History)
+	e.DELETE("/history", ClearHistory)
+	e.POST("/history/settings", HistorySettings)
+	e.POST("/history/:id/pin", PinHistory)
+	e.GET("/api/v1/history", HistoryEntries)
+	e.GET("/api/v1/history/:id", HistoryState)
+	return e
+}
+
+// History renders the query history of the client session.
+func History(c echo.Context) error {
+	return renderHistory(c)
+}
+
+// HistoryEntries responds with the query history of the client session as
+// JSON.
+func HistoryEntries(c echo.Context) error {
+	entries := []history.Entry{}
+	if historyEnabled(c) {
+		entries = append(entries, histories(c).Entries(SessionID(c))...)
+	}
+	return c.JSON(http.StatusOK, entries)
+}
+
+// HistoryState responds with the playground state of the entry of the query
+// history of the client session.
+func HistoryState(c echo.Context) error {
+	s, err := histories(c).State(SessionID(c), c.Param("id"))
+	if err != nil {
+		return &echo.HTTPError{
+			Code:     http.StatusNotFound,
+			Message:  "Not found",
+			Internal: err,
+		}
+	}
+	return c.JSON(http.StatusOK, s)
+}
+
+// ClearHistory removes every entry from the history of the client session.
+func ClearHistory(c echo.Context) error {
+	if err := histories(c).Clear(SessionID(c)); err != nil {
+		return err
+	}
+	return renderHistory(c)
+}
+
+// PinHistory pins or unpins an entry of the history depending on the pinned
+// form value.
+func PinHistory(c echo.Context) error {
+	_, err := histories(c).Pin(SessionID(c), c.Param("id"), c.FormValue("pinned") == "true")
+	if err != nil {
+		return &echo.HTTPError{
+			Code:     http.StatusNotFound,
+			Message:  "Not found",
+			Internal: err,
+		}
+	}
+	return renderHistory(c)
+}
+
+// HistorySettings turns the history of the client session on or off
+// depending on the enabled form value. Turning it off clears it, and the
+// inputs of the states recorded in it, as well.
+func HistorySettings(c echo.Context) error {
+	if c.FormValue("enabled") == "true" {
+		c.SetCookie(&http.Cookie{Name: historyCookie, Path: "/", MaxAge: -1})
+	} else {
+		if err := histories(c).Clear(SessionID(c)); err != nil {
+			return err
+		}
+		c.SetCookie(&http.Cookie{
+			Name:     historyCookie,
+			Value:    historyOff,
+			Path:     "/",
+			MaxAge:   int(sessionMaxAge / time.Second),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		c.Set(historyCookie, historyOff)
+	}
+	return renderHistory(c)
+}
+
+// historyEnabled reports whether the client keeps the history turned on.
+func historyEnabled(c echo.Context) bool {
+	if v, ok := c.Get(historyCookie).(string); ok {
+		return v != historyOff
+	}
+	ck, err := c.Cookie(historyCookie)
+	return err != nil || ck.Value != historyOff
+}
+
+// recordHistory records the playground state in the session history unless
+// the client turned it off. Only the first query of the playground is kept,
+// and states too large for the history are left out.
+func recordHistory(c echo.Context, req QueriesRequest, results []eval.Result) {
+	if !historyEnabled(c) || len(req.Queries) == 0 || req.DocumentID != "" {
+		return
+	}
+	s := snippet.Snippet{
+		Query:   req.Queries[0].Query,
+		Input:   req.TOMLData,
+		Options: req.Options,
+	}
+	if _, err := histories(c).Record(SessionID(c), s, history.Outcome(results)); err != nil {
+		if !errors.Is(err, history.ErrTooLarge) {
+			c.Logger().Error(err)
+		}
+		return
+	}
+	c.Response().Header().Set("HX-Trigger", "history")
+}
+
+func renderHistory(c echo.Context) error {
+	enabled := historyEnabled(c)
+	var entries []history.Entry
+	if enabled {
+		entries = histories(c).Entries(SessionID(c))
+	}
+	drawer := component.History(entries, enabled)
+	return drawer.Render(c.Request().Context(), c.Response().Writer)
+}
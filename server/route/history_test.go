@@ -0,0 +1,75 @@
+package route_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mdm-code/tqweb/server"
+	"github.com/mdm-code/tqweb/server/history"
+	"github.com/mdm-code/tqweb/server/snippet"
+)
+
+func TestHistoryStatesAreNotShared(t *testing.T) {
+	c := newClient(server.Server())
+	form := url.Values{"tqQuery": {`["secret"]`}, "tomlData": {"secret = 1\n"}, "run": {"true"}}
+	if rec := c.form(http.MethodPost, "/api/v1/live", form); rec.Code != http.StatusOK {
+		t.Fatalf("live: status %d", rec.Code)
+	}
+	var entries []history.Entry
+	rec := c.get("/api/v1/history")
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil || len(entries) != 1 {
+		t.Fatalf("history: %s, %v", rec.Body, err)
+	}
+	id := entries[0].ID
+
+	if rec := c.get("/?history=" + id); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "secret = 1") {
+		t.Errorf("restore: status %d", rec.Code)
+	}
+	var s snippet.Snippet
+	rec = c.get("/api/v1/history/" + id)
+	if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil || s.Input != "secret = 1\n" {
+		t.Errorf("state: status %d, %s", rec.Code, rec.Body)
+	}
+	for _, target := range []string{"/api/v1/snippets/" + id, "/embed/" + id} {
+		if rec := c.get(target); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want %d", target, rec.Code, http.StatusNotFound)
+		}
+	}
+	other := newClient(c.e)
+	for _, target := range []string{"/?history=" + id, "/api/v1/history/" + id} {
+		if rec := other.get(target); rec.Code != http.StatusNotFound {
+			t.Errorf("%s from another session: status %d", target, rec.Code)
+		}
+	}
+
+	if rec := c.do(http.MethodDelete, "/history", "", ""); rec.Code != http.StatusOK {
+		t.Fatalf("clear: status %d", rec.Code)
+	}
+	for _, target := range []string{"/?history=" + id, "/api/v1/history/" + id} {
+		if rec := c.get(target); rec.Code != http.StatusNotFound {
+			t.Errorf("%s after clear: status %d", target, rec.Code)
+		}
+	}
+}
+
+func TestServersDoNotShareSnippets(t *testing.T) {
+	a, b := newClient(server.Server()), newClient(server.Server())
+	form := url.Values{"tqQuery": {"."}, "tomlData": {"a = 1\n"}}
+	rec := a.form(http.MethodPost, "/api/v1/snippets", form)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("save: status %d", rec.Code)
+	}
+	var s struct{ ID string }
+	if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	}
+	if rec := a.get("/api/v1/snippets/" + s.ID); rec.Code != http.StatusOK {
+		t.Errorf("get from the same server: status %d", rec.Code)
+	}
+	if rec := b.get("/api/v1/snippets/" + s.ID); rec.Code != http.StatusNotFound {
+		t.Errorf("get from another server: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,77 @@
+package route
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mdm-code/tqweb/server/i18n"
+	"golang.org/x/text/language"
+)
+
+const languageCookie = "tqweb_lang"
+
+// Language negotiates the language of the user interface from the override
+// cookie and the Accept-Language header and carries it in the request
+// context.
+func Language(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		override := ""
+		if ck, err := c.Cookie(languageCookie); err == nil {
+			override = ck.Value
+		}
+		tag := i18n.Match(override, c.Request().Header.Get("Accept-Language"))
+		r := c.Request()
+		c.SetRequest(r.WithContext(i18n.WithLanguage(r.Context(), tag)))
+		h := c.Response().Header()
+		h.Set("Content-Language", tag.String())
+		h.Add(echo.HeaderVary, "Accept-Language")
+		h.Add(echo.HeaderVary, echo.HeaderCookie)
+		return next(c)
+	}
+}
+
+// SetLanguage overrides the negotiated language with the lang query parameter
+// and redirects back to the page the client came from.
+func SetLanguage(c echo.Context) error {
+	tag, err := language.Parse(c.QueryParam("lang"))
+	if err != nil {
+		return &echo.HTTPError{
+			Code:     http.StatusBadRequest,
+			Message:  "Bad request",
+			Internal: err,
+		}
+	}
+	// A regional tag like pl-PL selects the language of its base.
+	match := i18n.Match(tag.String(), "")
+	base, _ := tag.Base()
+	if matched, _ := match.Base(); base != matched {
+		return &echo.HTTPError{
+			Code:    http.StatusNotFound,
+			Message: "Not found",
+		}
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     languageCookie,
+		Value:    match.String(),
+		Path:     "/",
+		MaxAge:   int(sessionMaxAge / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return c.Redirect(http.StatusSeeOther, back(c.Request().Referer()))
+}
+
+// back returns the path and query of the referring page or the root if
+// there is none. The host is dropped and the leading slashes collapsed so
+// the redirect stays on tqweb.
+func back(referer string) string {
+	u, err := url.Parse(referer)
+	if err != nil {
+		return "/"
+	}
+	path := "/" + strings.TrimLeft(u.Path, "/")
+	return (&url.URL{Path: path, RawQuery: u.RawQuery}).String()
+}
@@ -0,0 +1,38 @@
+package route_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/mdm-code/tqweb/server"
+)
+
+func TestSetLanguage(t *testing.T) {
+	tests := []struct {
+		lang   string
+		status int
+		cookie string
+	}{
+		{"pl", http.StatusSeeOther, "pl"},
+		{"pl-PL", http.StatusSeeOther, "pl"},
+		{"en-GB", http.StatusSeeOther, "en"},
+		{"de", http.StatusNotFound, ""},
+		{"xx-invalid-", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		c := newClient(server.Server())
+		rec := c.get("/language?lang=" + tt.lang)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.lang, rec.Code, tt.status)
+		}
+		var cookie string
+		for _, ck := range c.cookies {
+			if ck.Name == "tqweb_lang" {
+				cookie = ck.Value
+			}
+		}
+		if cookie != tt.cookie {
+			t.Errorf("%s: language cookie %q, want %q", tt.lang, cookie, tt.cookie)
+		}
+	}
+}
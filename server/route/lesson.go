@@ -0,0 +1,84 @@
+package route
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mdm-code/tqweb/server/component"
+	"github.com/mdm-code/tqweb/server/cookie"
+	"github.com/mdm-code/tqweb/server/lesson"
+)
+
+const (
+	progressCookie = "tqweb_progress"
+	progressMaxAge = 365 * 24 * time.Hour
+)
+
+// RegisterLessonRoutes groups the tq tutorial routes.
+func RegisterLessonRoutes(e *echo.Echo) *echo.Echo {
+	g := e.Group("/learn")
+	g.GET("", Lessons)
+	g.GET("/:id", Lesson)
+	g.POST("/:id", GradeLesson)
+	return e
+}
+
+// Lessons lists the tutorial lessons with the learner progress.
+func Lessons(c echo.Context) error {
+	all, err := lesson.All()
+	if err != nil {
+		return err
+	}
+	lessons := component.Lessons(all, readProgress(c))
+	return lessons.Render(c.Request().Context(), c.Response().Writer)
+}
+
+// Lesson presents a single tutorial lesson.
+func Lesson(c echo.Context) error {
+	l, err := getLesson(c)
+	if err != nil {
+		return err
+	}
+	page := component.Lesson(l, readProgress(c).Done(l.ID))
+	return page.Render(c.Request().Context(), c.Response().Writer)
+}
+
+// GradeLesson grades the submitted query and records the progress when the
+// lesson is passed.
+func GradeLesson(c echo.Context) error {
+	l, err := getLesson(c)
+	if err != nil {
+		return err
+	}
+	g := l.Grade(c.FormValue("tqQuery"))
+	if g.Passed {
+		progress := readProgress(c).Complete(l.ID)
+		cookie.Default.Write(c, progressCookie, progress.String(), progressMaxAge)
+	}
+	next, hasNext := lesson.Next(l.ID)
+	grade := component.LessonGrade(g, next, hasNext)
+	return grade.Render(c.Request().Context(), c.Response().Writer)
+}
+
+func getLesson(c echo.Context) (lesson.Lesson, error) {
+	l, err := lesson.Get(c.Param("id"))
+	if err != nil {
+		return l, &echo.HTTPError{
+			Code:     http.StatusNotFound,
+			Message:  "Not found",
+			Internal: err,
+		}
+	}
+	return l, nil
+}
+
+// readProgress returns the lesson progress kept in the signed cookie. A
+// missing or tampered cookie counts as no progress.
+func readProgress(c echo.Context) lesson.Progress {
+	value, err := cookie.Default.Read(c, progressCookie)
+	if err != nil {
+		return nil
+	}
+	return lesson.ParseProgress(value)
+}
@@ -0,0 +1,94 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mdm-code/tqweb/server/component"
+	"github.com/mdm-code/tqweb/server/docstore"
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/mdm-code/tqweb/server/live"
+)
+
+// StaleHeader tells the client the live evaluation result is stale.
+const StaleHeader = "Tqweb-Stale"
+
+const liveSessionsKey = "tqweb_live_sessions"
+
+// UseLiveSessions makes the live evaluations tracked by the sessions.
+// ProcessLive expects it to be installed, as server.Server does.
+func UseLiveSessions(sessions *live.Sessions) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(liveSessionsKey, sessions)
+			return next(c)
+		}
+	}
+}
+
+func liveSessions(c echo.Context) *live.Sessions {
+	return c.Get(liveSessionsKey).(*live.Sessions)
+}
+
+// LiveResponse is the JSON body of a live evaluation response.
+type LiveResponse struct {
+	Stale   bool          `json:"stale"`
+	Results []eval.Result `json:"results"`
+}
+
+// ProcessLive runs the queries like ProcessQueries on every keystroke of the
+// playground. Each request cancels the evaluation still in flight for the
+// same client session, and the input decoded for the previous request of the
+// session is reused when it has not changed. A stale result, one superseded
+// by a newer request, is flagged in the response and not rendered for htmx so
+// that it does not overwrite the latest output. Evaluations run with the Run
+// button of the playground are recorded in the session history.
+func ProcessLive(c echo.Context) error {
+	req, err := bindQueries(c)
+	if err != nil {
+		return err
+	}
+	session := SessionID(c)
+	ctx, done := liveSessions(c).Begin(c.Request().Context(), session)
+	var results []eval.Result
+	var doc eval.Document
+	if req.DocumentID != "" {
+		doc, err = documents(c).Get(req.DocumentID)
+	} else {
+		doc, err = liveSessions(c).Decode(ctx, session, req.TOMLData)
+	}
+	if err == nil {
+		results, err = doc.RunQueriesContext(ctx, req.Queries, req.Options)
+	}
+	stale := done()
+	if stale {
+		c.Response().Header().Set(StaleHeader, "true")
+		if isJSON(c) {
+			return c.JSON(http.StatusOK, LiveResponse{Stale: true})
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+	if errors.Is(err, docstore.ErrNotFound) {
+		return &echo.HTTPError{
+			Code:     http.StatusNotFound,
+			Message:  "Not found",
+			Internal: err,
+		}
+	}
+	if err != nil {
+		return &echo.HTTPError{
+			Code:     http.StatusUnprocessableEntity,
+			Message:  "Unprocessable entity",
+			Internal: err,
+		}
+	}
+	if isJSON(c) {
+		return c.JSON(http.StatusOK, LiveResponse{Results: results})
+	}
+	if c.FormValue("run") == "true" {
+		recordHistory(c, req, results)
+	}
+	output := component.Results(results)
+	return output.Render(c.Request().Context(), c.Response().Writer)
+}
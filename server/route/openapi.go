@@ -0,0 +1,466 @@
+package route
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mdm-code/tqweb/server/batch"
+	"github.com/mdm-code/tqweb/server/cache"
+	"github.com/mdm-code/tqweb/server/codegen"
+	"github.com/mdm-code/tqweb/server/component"
+	"github.com/mdm-code/tqweb/server/convert"
+	"github.com/mdm-code/tqweb/server/docstore"
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/mdm-code/tqweb/server/format"
+	"github.com/mdm-code/tqweb/server/history"
+	"github.com/mdm-code/tqweb/server/openapi"
+	"github.com/mdm-code/tqweb/server/schema"
+	"github.com/mdm-code/tqweb/server/snippet"
+)
+
+// APIPrefix is the path prefix of the routes the API specification
+// describes.
+const APIPrefix = "/api/"
+
+const (
+	mimeForm      = echo.MIMEApplicationForm
+	mimeMultipart = echo.MIMEMultipartForm
+)
+
+// Spec returns the OpenAPI document describing the tqweb API.
+var Spec = sync.OnceValue(spec)
+
+// OpenAPI responds with the OpenAPI document describing the tqweb API.
+func OpenAPI(c echo.Context) error {
+	return c.JSON(http.StatusOK, Spec())
+}
+
+// APIDocs renders the API documentation from the OpenAPI document.
+func APIDocs(c echo.Context) error {
+	page := component.APIDocs(Spec())
+	return page.Render(c.Request().Context(), c.Response().Writer)
+}
+
+func spec() *openapi.Document {
+	d := openapi.New(openapi.Info{
+		Title:       "tqweb",
+		Description: "Run tq queries against TOML documents and work with TOML documents over HTTP. Most operations accept a JSON body as well as a form submission; JSON requests get JSON responses and form submissions get HTML fragments.",
+		Version:     "1",
+	})
+	d.SchemaOf(ErrorResponse{})
+	form := playgroundForm()
+	queries := jsonOrForm(d.SchemaOf(QueriesRequest{}), form)
+	snippetForm := playgroundForm()
+	snippetForm.Properties["title"] = &openapi.Schema{Type: "string"}
+
+	d.Add(http.MethodPost, "/api/v1/inputData", openapi.Operation{
+		OperationID: "processInputData",
+		Summary:     "Run a query and render its output",
+		Description: "Runs the tq query in tqQuery against the TOML input or the stored document and renders the output as HTML. Responses are cached.",
+		Tags:        []string{"queries"},
+		Parameters:  []openapi.Parameter{cacheParameter},
+		RequestBody: formBody(form),
+		Responses: responses(map[int]openapi.Response{
+			http.StatusOK:          cachedResponse(htmlContent()),
+			http.StatusNotModified: {Description: "The output matches the ETag in If-None-Match."},
+		}, http.StatusNotFound, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity),
+	})
+	d.Add(http.MethodPost, "/api/v1/queries", openapi.Operation{
+		OperationID: "processQueries",
+		Summary:     "Run many named queries",
+		Description: "Runs every named query against the same TOML input or stored document. A failing query does not hide the results of the other ones. Responses are cached.",
+		Tags:        []string{"queries"},
+		Parameters:  []openapi.Parameter{cacheParameter},
+		RequestBody: queries,
+		Responses: responses(map[int]openapi.Response{
+			http.StatusOK:          cachedResponse(jsonOrHTML(d.SchemaOf(QueriesResponse{}))),
+			http.StatusNotModified: {Description: "The results match the ETag in If-None-Match."},
+		}, http.StatusBadRequest, http.StatusNotFound, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity),
+	})
+	d.Add(http.MethodPost, "/api/v1/live", openapi.Operation{
+		OperationID: "processLive",
+		Summary:     "Run queries as the user types",
+		Description: "Runs the queries like processQueries and cancels the evaluation still in flight for the same client session. Stale results are flagged with the Tqweb-Stale header.",
+		Tags:        []string{"queries"},
+		RequestBody: queries,
+		Responses: responses(map[int]openapi.Response{
+			http.StatusOK: {
+				Description: "The results of the queries.",
+				Headers:     map[string]openapi.Header{StaleHeader: staleHeader},
+				Content:     jsonOrHTML(d.SchemaOf(LiveResponse{})),
+			},
+			http.StatusNoContent: {
+				Description: "A newer evaluation superseded this one.",
+				Headers:     map[string]openapi.Header{StaleHeader: staleHeader},
+			},
+		}, http.StatusBadRequest, http.StatusNotFound, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity),
+	})
+	d.Add(http.MethodPost, "/api/v1/query/validate", openapi.Operation{
+		OperationID: "validateQuery",
+		Summary:     "Validate a query",
+		Tags:        []string{"queries"},
+		RequestBody: formBody(object(map[string]*openapi.Schema{"tqQuery": {Type: "string"}}, "tqQuery")),
+		Responses: responses(map[int]openapi.Response{
+			http.StatusOK: {Description: "The query is valid."},
+		}, http.StatusUnprocessableEntity),
+	})
+	d.Add(http.MethodPost, "/api/v1/query/format", openapi.Operation{
+		OperationID: "formatQuery",
+		Summary:     "Format a query",
+		Description: "Rewrites the query in its canonical form.",
+		Tags:        []string{"queries"},
+		RequestBody: jsonOrForm(d.SchemaOf(QueryRequest{}), object(map[string]*openapi.Schema{"tqQuery": {Type: "string"}}, "tqQuery")),
+		Responses: responses(map[int]openapi.Response{
+			http.StatusOK: {
+				Description: "The formatted query.",
+				Content: map[string]openapi.MediaType{
+					echo.MIMEApplicationJSON: {Schema: d.SchemaOf(QueryRequest{})},
+					echo.MIMETextPlain:       {Schema: &openapi.Schema{Type: "string"}},
+				},
+			},
+		}, http.StatusBadRequest, http.StatusUnprocessableEntity),
+	})
+	d.Add(http.MethodPost, "/api/v1/snippets", openapi.Operation{
+		OperationID: "saveSnippet",
+		Summary:     "Save a snippet",
+		Description: "Saves a query with its input and output options. Saving the same state twice yields the same snippet.",
+		Tags:        []string{"snippets"},
+		RequestBody: jsonOrForm(object(map[string]*openapi.Schema{
+			"title":   {Type: "string"},
+			"query":   {Type: "string"},
+			"input":   {Type: "string"},
+			"options": d.SchemaOf(eval.Options{}),
+		}, "query", "input"), snippetForm),
+		Responses: responses(map[int]openapi.Response{
+			http.StatusCreated: jsonResponse("The saved snippet with the link restoring it.", d.SchemaOf(SnippetResponse{})),
+		}, http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity),
+	})
+	d.Add(http.MethodGet, "/api/v1/snippets/{id}", openapi.Operation{
+		OperationID: "getSnippet",
+		Summary:     "Get a snippet",
+		Tags:        []string{"snippets"},
+		Parameters:  []openapi.Parameter{idParameter("The ID of the snippet.")},
+		Responses: responses(map[int]openapi.Response{
+			http.StatusOK: jsonResponse("The snippet.", d.SchemaOf(snippet.Snippet{})),
+		}, http.StatusNotFound),
+	})
+	d.Add(http.MethodPost, "/api/v1/toml/validate", openapi.Operation{
+		OperationID: "validateTOML",
+		Summary:     "Validate a TOML document",
+		Tags:        []string{"toml"},
+		RequestBody: formBody(tomlForm(nil)),
+		Responses: responses(map[int]openapi.Response{
+			http.StatusOK: {Description: "The document is valid."},
+		}, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity),
+	})
+	d.Add(http.MethodPost, "/api/v1/toml/upload", openapi.Operation{
+		OperationID: "uploadTOML",
+		Summary:     "Upload a TOML document",
+		Description: "Reads the uploaded document and renders it into the TOML input field of the playground.",
+		Tags:        []string{"toml"},
+		RequestBody: formBody(tomlForm(nil)),
+		Responses: responses(map[int]openapi.Response{
+			http.StatusOK: {Description: "The TOML input field.", Content: htmlContent()},
+		}, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity),
+	})
+	d.Add(http.MethodPost, "/api/v1/toml/format", openapi.Operation{
+		OperationID: "formatTOML",
+		Summary:     "Format a TOML document",
+		Description: "Decodes the document and encodes it back with the output options, listing the features of the source lost in the round trip.",
+		Tags:        []string{"toml"},
+		RequestBody: jsonOrForm(d.SchemaOf(FormatRequest{}), tomlForm(optionFields())),
+		Responses: responses(map[int]openapi.Response{
+			http.StatusOK: {Description: "The formatted document.", Content: jsonOrHTML(d.SchemaOf(format.Result{}))},
+		}, http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity),
+	})
+	d.Add(http.MethodPost, "/api/v1/convert", openapi.Operation{
+		OperationID: "convert",
+		Summary:     "Convert between TOML and JSON",
+		Description: "Converts the input document between the formats given in the from and to parameters, listing the values that could not be converted.",
+		Tags:        []string{"toml"},
+		Parameters: []openapi.Parameter{
+			formatParameter("from", "The format of the input."),
+			formatParameter("to", "The format of the output."),
+		},
+		RequestBody: jsonOrForm(d.SchemaOf(ConvertRequest{}), object(mergeFields(optionFields(), map[string]*openapi.Schema{"input": {Type: "string"}}), "input")),
+		Responses: responses(map[int]openapi.Response{
+			http.StatusOK: {Description: "The converted document.", Content: jsonOrHTML(d.SchemaOf(convert.Result{}))},
+		}, http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity),
+	})
+	d.Add(http.MethodPost, "/api/v1/toml/structs", openapi.Operation{
+		OperationID: "generateStructs",
+		Summary:     "Generate Go types",
+		Description: "Generates Go types with toml struct tags for the document.",
+		Tags:        []string{"toml"},
+		RequestBody: jsonOrForm(d.SchemaOf(StructsRequest{}), tomlForm(map[string]*openapi.Schema{
+			"package":  {Type: "string"},
+			"root":     {Type: "string"},
+			"naming":   {Type: "string", Enum: []string{codegen.NamingGo, codegen.NamingCamel}},
+			"pointers": {Type: "boolean"},
+		})),
+		Responses: responses(map[int]openapi.Response{
+			http.StatusOK: {Description: "The generated Go source.", Content: jsonOrHTML(d.SchemaOf(codegen.Result{}))},
+		}, http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity),
+	})
+	d.Add(http.MethodPost, "/api/v1/toml/schema", openapi.Operation{
+		OperationID: "inferSchema",
+		Summary:     "Infer a JSON Schema",
+		Description: "Infers the JSON Schema of the TOML document.",
+		Tags:        []string{"schema"},
+		RequestBody: jsonOrForm(d.SchemaOf(SchemaRequest{}), tomlForm(nil)),
+		Responses: responses(map[int]openapi.Response{
+			http.StatusOK: {Description: "The inferred schema.", Content: jsonOrHTML(d.SchemaOf(schema.Schema{}))},
+		}, http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity),
+	})
+	d.Add(http.MethodPost, "/api/v1/toml/schema/validate", openapi.Operation{
+		OperationID: "validateSchema",
+		Summary:     "Validate against a JSON Schema",
+		Description: "Validates the TOML document against the schema and lists the violations with the tq query paths of the offending values.",
+		Tags:        []string{"schema"},
+		RequestBody: jsonOrForm(d.SchemaOf(SchemaRequest{}), tomlForm(map[string]*openapi.Schema{"schema": {Type: "string"}})),
+		Responses: responses(map[int]openapi.Response{
+			http.StatusOK: {Description: "The violations.", Content: jsonOrHTML(d.SchemaOf(SchemaResponse{}))},
+		}, http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity),
+	})
+	d.Add(http.MethodPost, "/api/v1/diff", openapi.Operation{
+		OperationID: "diff",
+		Summary:     "Compare two TOML documents",
+		Description: "Compares the documents structurally, optionally narrowed down by a query applied to both of them.",
+		Tags:        []string{"toml"},
+		RequestBody: jsonOrForm(d.SchemaOf(DiffRequest{}), object(map[string]*openapi.Schema{
+			"left":    {Type: "string"},
+			"right":   {Type: "string"},
+			"tqQuery": {Type: "string"},
+		}, "left", "right")),
+		Responses: responses(map[int]openapi.Response{
+			http.StatusOK: {Description: "The differences.", Content: jsonOrHTML(d.SchemaOf(DiffResponse{}))},
+		}, http.StatusBadRequest, http.StatusUnprocessableEntity),
+	})
+	d.Add(http.MethodPost, "/api/v1/batch", openapi.Operation{
+		OperationID: "processBatch",
+		Summary:     "Run a query over many documents",
+		Description: "Runs the query over every uploaded TOML document, and the documents in uploaded zip archives, streaming the results one file at a time. A batch holds up to " + strconv.Itoa(batch.MaxEntries) + " documents and every zip archive up to as many entries.",
+		Tags:        []string{"queries"},
+		Parameters: []openapi.Parameter{{
+			Name:        "format",
+			In:          "query",
+			Description: "The format of the results.",
+			Schema:      &openapi.Schema{Type: "string", Enum: []string{"ndjson", "json", "csv"}},
+		}},
+		RequestBody: &openapi.RequestBody{
+			Required: true,
+			Content: map[string]openapi.MediaType{
+				mimeMultipart: {Schema: object(mergeFields(optionFields(), map[string]*openapi.Schema{
+					"tqQuery": {Type: "string"},
+					"files":   {Type: "array", Items: &openapi.Schema{Type: "string", Format: "binary"}},
+				}), "tqQuery", "files")},
+			},
+		},
+		Responses: responses(map[int]openapi.Response{
+			http.StatusOK: {
+				Description: "The results per file.",
+				Content: map[string]openapi.MediaType{
+					batchContentTypes["ndjson"]: {Schema: d.SchemaOf(batch.Result{})},
+					batchContentTypes["json"]:   {Schema: &openapi.Schema{Type: "array", Items: d.SchemaOf(batch.Result{})}},
+					batchContentTypes["csv"]:    {Schema: &openapi.Schema{Type: "string"}},
+				},
+			},
+		}, http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity),
+	})
+	d.Add(http.MethodPost, "/api/v1/documents", openapi.Operation{
+		OperationID: "storeDocument",
+		Summary:     "Store a decoded document",
+		Description: "Decodes the TOML document and stores it, so that the query operations can be given its ID in documentId in place of the input. Stored documents expire when unused.",
+		Tags:        []string{"documents"},
+		RequestBody: jsonOrForm(d.SchemaOf(DocumentRequest{}), tomlForm(nil)),
+		Responses: responses(map[int]openapi.Response{
+			http.StatusCreated: jsonResponse("The stored document.", d.SchemaOf(docstore.Info{})),
+		}, http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity),
+	})
+	d.Add(http.MethodDelete, "/api/v1/documents/{id}", openapi.Operation{
+		OperationID: "deleteDocument",
+		Summary:     "Delete a stored document",
+		Tags:        []string{"documents"},
+		Parameters:  []openapi.Parameter{idParameter("The ID of the stored document.")},
+		Responses: responses(map[int]openapi.Response{
+			http.StatusNoContent: {Description: "The document is deleted."},
+		}, http.StatusNotFound),
+	})
+	d.Add(http.MethodGet, "/api/v1/history", openapi.Operation{
+		OperationID: "historyEntries",
+		Summary:     "List the query history",
+		Description: "Lists the query history of the client session, identified by its cookie.",
+		Tags:        []string{"snippets"},
+		Responses: responses(map[int]openapi.Response{
+			http.StatusOK: jsonResponse("The history entries.", d.SchemaOf([]history.Entry{})),
+		}),
+	})
+	d.Add(http.MethodGet, "/api/v1/history/{id}", openapi.Operation{
+		OperationID: "historyState",
+		Summary:     "Get a state from the query history",
+		Description: "Returns the playground state of the entry of the query history of the client session.",
+		Tags:        []string{"snippets"},
+		Parameters:  []openapi.Parameter{idParameter("The ID of the history entry.")},
+		Responses: responses(map[int]openapi.Response{
+			http.StatusOK: jsonResponse("The playground state.", d.SchemaOf(snippet.Snippet{})),
+		}, http.StatusNotFound),
+	})
+	d.Add(http.MethodGet, "/api/v1/cache", openapi.Operation{
+		OperationID: "cacheStats",
+		Summary:     "Get the result cache metrics",
+		Tags:        []string{"meta"},
+		Responses: responses(map[int]openapi.Response{
+			http.StatusOK: jsonResponse("The cache metrics.", d.SchemaOf(cache.Stats{})),
+		}),
+	})
+	d.Add(http.MethodGet, "/api/v1/openapi.json", openapi.Operation{
+		OperationID: "openAPI",
+		Summary:     "Get this specification",
+		Tags:        []string{"meta"},
+		Responses: responses(map[int]openapi.Response{
+			http.StatusOK: jsonResponse("The OpenAPI document.", &openapi.Schema{Type: "object"}),
+		}),
+	})
+	return d
+}
+
+var (
+	cacheParameter = openapi.Parameter{
+		Name:        CacheHeader,
+		In:          "header",
+		Description: "Set to bypass to skip the result cache.",
+		Schema:      &openapi.Schema{Type: "string", Enum: []string{"bypass"}},
+	}
+	staleHeader = openapi.Header{
+		Description: "Set to true when a newer evaluation superseded this one.",
+		Schema:      &openapi.Schema{Type: "string"},
+	}
+)
+
+// responses adds the error responses with the status codes to the
+// responses of an operation.
+func responses(ok map[int]openapi.Response, codes ...int) map[string]openapi.Response {
+	r := make(map[string]openapi.Response, len(ok)+len(codes))
+	for code, resp := range ok {
+		r[strconv.Itoa(code)] = resp
+	}
+	for _, code := range codes {
+		r[strconv.Itoa(code)] = openapi.Response{
+			Description: http.StatusText(code) + ".",
+			Content: map[string]openapi.MediaType{
+				echo.MIMEApplicationJSON: {Schema: &openapi.Schema{Ref: "#/components/schemas/ErrorResponse"}},
+				echo.MIMETextHTML:        {Schema: &openapi.Schema{Type: "string"}},
+			},
+		}
+	}
+	return r
+}
+
+func cachedResponse(content map[string]openapi.MediaType) openapi.Response {
+	return openapi.Response{
+		Description: "The result, served from the cache when possible.",
+		Headers: map[string]openapi.Header{
+			CacheHeader: {
+				Description: "Whether the result was a cache hit, miss or bypass.",
+				Schema:      &openapi.Schema{Type: "string", Enum: []string{"hit", "miss", "bypass"}},
+			},
+			"ETag": {Description: "The entity tag of the result.", Schema: &openapi.Schema{Type: "string"}},
+		},
+		Content: content,
+	}
+}
+
+func jsonResponse(description string, s *openapi.Schema) openapi.Response {
+	return openapi.Response{
+		Description: description,
+		Content:     map[string]openapi.MediaType{echo.MIMEApplicationJSON: {Schema: s}},
+	}
+}
+
+func htmlContent() map[string]openapi.MediaType {
+	return map[string]openapi.MediaType{echo.MIMETextHTML: {Schema: &openapi.Schema{Type: "string"}}}
+}
+
+func jsonOrHTML(s *openapi.Schema) map[string]openapi.MediaType {
+	return map[string]openapi.MediaType{
+		echo.MIMEApplicationJSON: {Schema: s},
+		echo.MIMETextHTML:        {Schema: &openapi.Schema{Type: "string"}},
+	}
+}
+
+func formBody(form *openapi.Schema) *openapi.RequestBody {
+	return &openapi.RequestBody{
+		Required: true,
+		Content:  map[string]openapi.MediaType{mimeForm: {Schema: form}, mimeMultipart: {Schema: form}},
+	}
+}
+
+func jsonOrForm(body, form *openapi.Schema) *openapi.RequestBody {
+	r := formBody(form)
+	r.Content[echo.MIMEApplicationJSON] = openapi.MediaType{Schema: body}
+	return r
+}
+
+func object(properties map[string]*openapi.Schema, required ...string) *openapi.Schema {
+	return &openapi.Schema{Type: "object", Properties: properties, Required: required}
+}
+
+func mergeFields(a, b map[string]*openapi.Schema) map[string]*openapi.Schema {
+	for k, v := range b {
+		a[k] = v
+	}
+	return a
+}
+
+// optionFields describes the form fields of the output options.
+func optionFields() map[string]*openapi.Schema {
+	return map[string]*openapi.Schema{
+		"tablesInline":    {Type: "boolean"},
+		"arraysMultiline": {Type: "boolean"},
+		"indentSymbol":    {Type: "string"},
+		"indentTables":    {Type: "boolean"},
+	}
+}
+
+// tomlForm describes a form with the TOML input, given either in the
+// tomlData field or as the tomlFile upload, and the extra fields.
+func tomlForm(fields map[string]*openapi.Schema) *openapi.Schema {
+	properties := map[string]*openapi.Schema{
+		"tomlData": {Type: "string", Description: "The TOML input."},
+		"tomlFile": {Type: "string", Format: "binary", Description: "The TOML input uploaded as a file in place of tomlData."},
+	}
+	return object(mergeFields(properties, fields))
+}
+
+// playgroundForm describes the form of the playground.
+func playgroundForm() *openapi.Schema {
+	fields := mergeFields(optionFields(), map[string]*openapi.Schema{
+		"tqQuery":    {Type: "array", Items: &openapi.Schema{Type: "string"}, Description: "The queries, repeated for many named queries."},
+		"queryName":  {Type: "array", Items: &openapi.Schema{Type: "string"}, Description: "The names of the queries in the same order."},
+		"documentId": {Type: "string", Description: "The ID of a stored document used in place of the input."},
+		"run":        {Type: "boolean", Description: "Records the evaluation in the session history."},
+	})
+	return tomlForm(fields)
+}
+
+func idParameter(description string) openapi.Parameter {
+	return openapi.Parameter{
+		Name:        "id",
+		In:          "path",
+		Description: description,
+		Required:    true,
+		Schema:      &openapi.Schema{Type: "string"},
+	}
+}
+
+func formatParameter(name, description string) openapi.Parameter {
+	return openapi.Parameter{
+		Name:        name,
+		In:          "query",
+		Description: description,
+		Required:    true,
+		Schema:      &openapi.Schema{Type: "string", Enum: []string{convert.TOML, convert.JSON}},
+	}
+}
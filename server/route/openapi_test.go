@@ -0,0 +1,46 @@
+package route_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mdm-code/tqweb/server"
+	"github.com/mdm-code/tqweb/server/openapi"
+	"github.com/mdm-code/tqweb/server/route"
+)
+
+func TestSpecDescribesRoutes(t *testing.T) {
+	e := server.Server()
+	if err := route.Spec().Verify(e.Routes(), route.APIPrefix); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSpecReportsUndescribedRoutes(t *testing.T) {
+	e := server.Server()
+	e.GET("/api/v1/undescribed", func(c echo.Context) error { return nil })
+	err := route.Spec().Verify(e.Routes(), route.APIPrefix)
+	if !errors.Is(err, openapi.ErrMismatch) {
+		t.Errorf("Verify() = %v, want %v", err, openapi.ErrMismatch)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	e := server.Server()
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	var doc openapi.Document
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Paths) != len(route.Spec().Paths) {
+		t.Errorf("served %d paths, want %d", len(doc.Paths), len(route.Spec().Paths))
+	}
+}
@@ -0,0 +1,138 @@
+package route
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mdm-code/tqweb/server/cache"
+	"github.com/mdm-code/tqweb/server/component"
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/mdm-code/tqweb/server/upload"
+)
+
+// QueriesRequest is the JSON body of a request running many named queries
+// against the same TOML input. The ID of a stored document can be given in place of the input.
+type QueriesRequest struct {
+	TOMLData   string       `json:"tomlData"`
+	DocumentID string       `json:"documentId,omitempty"`
+	Queries    []eval.Query `json:"queries"`
+	Options    eval.Options `json:"options"`
+}
+
+// QueriesResponse is the JSON body of the response with per-query results.
+type QueriesResponse struct {
+	Results []eval.Result `json:"results"`
+}
+
+// NewQueryRow renders another named query field for the playground form.
+func NewQueryRow(c echo.Context) error {
+	row := component.QueryRow()
+	return row.Render(c.Request().Context(), c.Response().Writer)
+}
+
+// ProcessQueries runs a list of named tq queries against a single TOML input.
+// The input is decoded once and each query is evaluated on its own, so a
+// failing query does not hide the results of the other ones. JSON requests
+// get a JSON response, form submissions get the rendered results. Responses
+// are cached by the queries, the input, the output options and, for rendered
+// results, the language.
+func ProcessQueries(c echo.Context) error {
+	req, err := bindQueries(c)
+	if err != nil {
+		return err
+	}
+	format := htmlFormat(c)
+	if isJSON(c) {
+		format = "json"
+	}
+	input, err := cacheInput(c, req.DocumentID, req.TOMLData)
+	if err != nil {
+		return err
+	}
+	key := cache.NewKey(queriesKey(req.Queries), input, req.Options.Conf(), format)
+	return cached(c, key, func() (cache.Entry, error) {
+		doc, err := document(c, req.DocumentID, req.TOMLData)
+		if err != nil {
+			return cache.Entry{}, err
+		}
+		results := doc.RunQueries(req.Queries, req.Options)
+		if format == "json" {
+			body, err := json.Marshal(QueriesResponse{Results: results})
+			if err != nil {
+				return cache.Entry{}, err
+			}
+			return cache.NewEntry(echo.MIMEApplicationJSON, body), nil
+		}
+		var body bytes.Buffer
+		output := component.Results(results)
+		if err := output.Render(c.Request().Context(), &body); err != nil {
+			return cache.Entry{}, err
+		}
+		return cache.NewEntry(echo.MIMETextHTMLCharsetUTF8, body.Bytes()), nil
+	})
+}
+
+// queriesKey encodes the named queries as a single cache key part.
+func queriesKey(queries []eval.Query) string {
+	b, _ := json.Marshal(queries)
+	return string(b)
+}
+
+// bindQueries reads the queries request from either the JSON body or the
+// submitted form. All errors are returned as HTTP errors.
+func bindQueries(c echo.Context) (QueriesRequest, error) {
+	var req QueriesRequest
+	if isJSON(c) {
+		err := (&echo.DefaultBinder{}).BindBody(c, &req)
+		if err != nil {
+			return req, &echo.HTTPError{
+				Code:     http.StatusBadRequest,
+				Message:  "Bad request",
+				Internal: err,
+			}
+		}
+		req.TOMLData, err = upload.Normalize([]byte(req.TOMLData))
+		if err != nil {
+			return req, uploadError(err)
+		}
+	} else {
+		form, err := c.FormParams()
+		if err != nil {
+			return req, &echo.HTTPError{
+				Code:     http.StatusBadRequest,
+				Message:  "Bad request",
+				Internal: err,
+			}
+		}
+		names := form["queryName"]
+		for i, q := range form["tqQuery"] {
+			var name string
+			if i < len(names) {
+				name = names[i]
+			}
+			req.Queries = append(req.Queries, eval.Query{Name: name, Query: q})
+		}
+		req.DocumentID = c.FormValue("documentId")
+		if req.DocumentID == "" {
+			req.TOMLData, err = TOMLInput(c)
+			if err != nil {
+				return req, err
+			}
+		}
+		req.Options = FormOptions(c)
+	}
+	for i := range req.Queries {
+		if strings.TrimSpace(req.Queries[i].Name) == "" {
+			req.Queries[i].Name = fmt.Sprintf("query %d", i+1)
+		}
+	}
+	return req, nil
+}
+
+func isJSON(c echo.Context) bool {
+	return strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON)
+}
@@ -0,0 +1,45 @@
+package route_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/mdm-code/tqweb/server"
+	"github.com/mdm-code/tqweb/server/route"
+)
+
+func TestProcessQueriesCachesPerLanguage(t *testing.T) {
+	e := server.Server()
+	form := url.Values{"tqQuery": {`["a"]`, "["}, "tomlData": {"a = 1\n"}}
+	run := func(lang string) *httptest.ResponseRecorder {
+		c := newClient(e)
+		c.header.Set("Accept-Language", lang)
+		rec := c.form(http.MethodPost, "/api/v1/queries", form)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d", lang, rec.Code)
+		}
+		return rec
+	}
+	en, pl := run("en"), run("pl")
+	if pl.Header().Get(route.CacheHeader) != "miss" {
+		t.Errorf("pl: cache %s, want miss", pl.Header().Get(route.CacheHeader))
+	}
+	if en.Body.String() == pl.Body.String() || en.Header().Get("ETag") == pl.Header().Get("ETag") {
+		t.Error("English and Polish results share the cached body")
+	}
+	if again := run("pl"); again.Header().Get(route.CacheHeader) != "hit" || again.Body.String() != pl.Body.String() {
+		t.Errorf("pl again: cache %s", again.Header().Get(route.CacheHeader))
+	}
+}
+
+func TestServersDoNotShareResults(t *testing.T) {
+	form := url.Values{"tqQuery": {`["a"]`}, "tomlData": {"a = 1\n"}}
+	for i := range 2 {
+		rec := newClient(server.Server()).form(http.MethodPost, "/api/v1/queries", form)
+		if got := rec.Header().Get(route.CacheHeader); got != "miss" {
+			t.Errorf("server %d: cache %s, want miss", i, got)
+		}
+	}
+}
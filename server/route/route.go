@@ -8,7 +8,11 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/mdm-code/tq"
 	"github.com/mdm-code/tq/toml"
+	"github.com/mdm-code/tqweb/server/cache"
 	"github.com/mdm-code/tqweb/server/component"
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/mdm-code/tqweb/server/example"
+	"github.com/mdm-code/tqweb/server/filter"
 )
 
 const (
@@ -16,12 +20,36 @@ const (
 	staticPathPrefix = "/assets"
 )
 
+// ErrorResponse is the JSON body of an error response. The detail and the
+// explanation are given for errors caused by the input of the request.
+type ErrorResponse struct {
+	Message     string `json:"message"`
+	Detail      string `json:"detail,omitempty"`
+	Explanation string `json:"explanation,omitempty"`
+}
+
 // RegisterAll registers all routes defined for the HTTP server.
 func RegisterAll(e *echo.Echo) *echo.Echo {
 	return ServeStatics(
 		RegsiterRootRoutes(
-			RegisterProcessRoutes(
-				e,
+			RegisterEmbedRoutes(
+				RegisterHistoryRoutes(
+					RegisterDocumentRoutes(
+						RegisterSchemaRoutes(
+							RegisterDiffRoutes(
+								RegisterBatchRoutes(
+									RegisterLessonRoutes(
+										RegisterExampleRoutes(
+											RegisterProcessRoutes(
+												e,
+											),
+										),
+									),
+								),
+							),
+						),
+					),
+				),
 			),
 		),
 		staticFsRoot,
@@ -39,56 +67,141 @@ func ServeStatics(e *echo.Echo, at, from string) *echo.Echo {
 // RegsiterRootRoutes groups root routes.
 func RegsiterRootRoutes(e *echo.Echo) *echo.Echo {
 	e.GET("/", Index)
+	e.GET("/reference", Reference)
+	e.GET("/queries/new", NewQueryRow)
+	e.GET("/format", FormatPage)
+	e.GET("/convert", ConvertPage)
+	e.GET("/structs", StructsPage)
+	e.GET("/language", SetLanguage)
+	e.GET("/docs", APIDocs)
 	return e
 }
 
 // RegisterProcessRoutes groups data processing routes.
 func RegisterProcessRoutes(e *echo.Echo) *echo.Echo {
 	g := e.Group("/api/v1")
-	g.POST("/inputData", ProcessInputData)
+	g.POST("/inputData", ProcessInputData, LimitBody)
+	g.POST("/queries", ProcessQueries, LimitBody)
+	g.POST("/live", ProcessLive, LimitBody)
 	g.POST("/query/validate", ValidateTqQuery)
-	g.POST("/toml/validate", ValidateTOML)
+	g.POST("/query/format", FormatQuery)
+	g.POST("/snippets", SaveSnippet, LimitBody)
+	g.GET("/snippets/:id", GetSnippet)
+	g.POST("/toml/validate", ValidateTOML, LimitBody)
+	g.POST("/toml/upload", UploadTOML, LimitBody)
+	g.POST("/toml/format", FormatTOML, LimitBody)
+	g.POST("/convert", Convert, LimitBody)
+	g.POST("/toml/structs", GenerateStructs, LimitBody)
+	g.GET("/cache", CacheStats)
+	g.GET("/openapi.json", OpenAPI)
 	return e
 }
 
-// Index route for the tqweb.
+// Index route for the tqweb. The playground is filled with the example, the
+// snippet or the state from the session history given in the example,
+// snippet or history query parameter if there is one.
 func Index(c echo.Context) error {
-	index := component.Index()
+	var p component.Playground
+	if id := c.QueryParam("example"); id != "" {
+		e, err := example.Get(id)
+		if err != nil {
+			return &echo.HTTPError{
+				Code:     http.StatusNotFound,
+				Message:  "Not found",
+				Internal: err,
+			}
+		}
+		p = component.Playground{Query: e.Query, Input: e.Input, Options: e.Flags}
+	}
+	if id := c.QueryParam("snippet"); id != "" {
+		s, err := snippets(c).Get(id)
+		if err != nil {
+			return &echo.HTTPError{
+				Code:     http.StatusNotFound,
+				Message:  "Not found",
+				Internal: err,
+			}
+		}
+		p = component.Playground{
+			Query:   s.Query,
+			Input:   s.Input,
+			Options: s.Options,
+			OEmbed:  oembedURL(c, c.Scheme()+"://"+c.Request().Host+"/?snippet="+s.ID),
+		}
+	}
+	if id := c.QueryParam("history"); id != "" {
+		s, err := histories(c).State(SessionID(c), id)
+		if err != nil {
+			return &echo.HTTPError{
+				Code:     http.StatusNotFound,
+				Message:  "Not found",
+				Internal: err,
+			}
+		}
+		p = component.Playground{Query: s.Query, Input: s.Input, Options: s.Options}
+	}
+	index := component.Index(p)
 	err := index.Render(c.Request().Context(), c.Response().Writer)
 	return err
 }
 
-// ProcessInputData runs the tq query against the provided TOML data.
+// Reference documents the filters supported by tq.
+func Reference(c echo.Context) error {
+	reference := component.Reference(filter.All())
+	return reference.Render(c.Request().Context(), c.Response().Writer)
+}
+
+// ProcessInputData runs the tq query against the provided TOML data or the
+// stored document given by its ID. The rendered output is cached by the
+// query, the input, the output options and the language.
 func ProcessInputData(c echo.Context) error {
 	query := c.FormValue("tqQuery")
-	tomlData := c.FormValue("tomlData")
-
-	input := strings.NewReader(tomlData)
-	var output bytes.Buffer
-
-	// TODO: Config data should be provided through the form as well.
-	tomlAdapter := toml.NewAdapter(toml.NewGoTOML(toml.GoTOMLConf{}))
-	tq := tq.New(tomlAdapter)
-	if err := tq.Run(input, &output, query); err != nil {
-		return &echo.HTTPError{
-			Code:     http.StatusUnprocessableEntity,
-			Message:  "Unprocessable entity",
-			Internal: err,
+	id := c.FormValue("documentId")
+	var tomlData string
+	if id == "" {
+		var err error
+		if tomlData, err = TOMLInput(c); err != nil {
+			return err
 		}
 	}
-	// TODO: Otherwise try to render the output HTML with output.String().
-
-	// TODO: Extend output TOML validation.
-	_ = func(output string) bool {
-		var data any
-		r := strings.NewReader(output)
-		if err := tomlAdapter.Unmarshal(r, &data); err != nil {
-			return false
+	o := FormOptions(c)
+	input, err := cacheInput(c, id, tomlData)
+	if err != nil {
+		return err
+	}
+	key := cache.NewKey(query, input, o.Conf(), htmlFormat(c))
+	return cached(c, key, func() (cache.Entry, error) {
+		doc, err := document(c, id, tomlData)
+		if err != nil {
+			return cache.Entry{}, err
+		}
+		result, err := doc.Run(query, o)
+		if err != nil {
+			return cache.Entry{}, &echo.HTTPError{
+				Code:     http.StatusUnprocessableEntity,
+				Message:  "Unprocessable entity",
+				Internal: err,
+			}
 		}
-		return true
-	}(output.String())
 
-	return nil
+		// TODO: Extend output TOML validation.
+		var body bytes.Buffer
+		output := component.Output(result)
+		if err := output.Render(c.Request().Context(), &body); err != nil {
+			return cache.Entry{}, err
+		}
+		return cache.NewEntry(echo.MIMETextHTMLCharsetUTF8, body.Bytes()), nil
+	})
+}
+
+// FormOptions reads the tq output flags from the submitted form.
+func FormOptions(c echo.Context) eval.Options {
+	return eval.Options{
+		TablesInline:    c.FormValue("tablesInline") == "true",
+		ArraysMultiline: c.FormValue("arraysMultiline") == "true",
+		IndentSymbol:    c.FormValue("indentSymbol"),
+		IndentTables:    c.FormValue("indentTables") == "true",
+	}
 }
 
 // ValidateTqQuery verifies if the provided tq query string is valid.
@@ -107,9 +220,46 @@ func ValidateTqQuery(c echo.Context) error {
 	return nil
 }
 
+// QueryRequest is the JSON body of a request formatting a tq query.
+type QueryRequest struct {
+	Query string `json:"query"`
+}
+
+// FormatQuery rewrites the tq query in its canonical form. JSON requests get
+// the query back in the same JSON shape, form submissions as plain text.
+func FormatQuery(c echo.Context) error {
+	var req QueryRequest
+	if isJSON(c) {
+		if err := (&echo.DefaultBinder{}).BindBody(c, &req); err != nil {
+			return &echo.HTTPError{
+				Code:     http.StatusBadRequest,
+				Message:  "Bad request",
+				Internal: err,
+			}
+		}
+	} else {
+		req.Query = c.FormValue("tqQuery")
+	}
+	query, err := filter.Format(req.Query)
+	if err != nil {
+		return &echo.HTTPError{
+			Code:     http.StatusUnprocessableEntity,
+			Message:  "Unprocessable entity",
+			Internal: err,
+		}
+	}
+	if isJSON(c) {
+		return c.JSON(http.StatusOK, QueryRequest{Query: query})
+	}
+	return c.String(http.StatusOK, query)
+}
+
 // ValidateTOML checks if the provided form input is a valid TOML document.
 func ValidateTOML(c echo.Context) error {
-	tomlData := c.FormValue("tomlData")
+	tomlData, err := TOMLInput(c)
+	if err != nil {
+		return err
+	}
 	tomlAdapter := toml.NewAdapter(toml.NewGoTOML(toml.GoTOMLConf{}))
 	var data any
 	reader := strings.NewReader(tomlData)
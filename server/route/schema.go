@@ -0,0 +1,126 @@
+package route
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mdm-code/tqweb/server/component"
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/mdm-code/tqweb/server/schema"
+	"github.com/mdm-code/tqweb/server/upload"
+)
+
+// SchemaRequest is the JSON body of a request inferring the schema of a TOML
+// document or validating the document against a schema.
+type SchemaRequest struct {
+	TOMLData string          `json:"tomlData"`
+	Schema   json.RawMessage `json:"schema,omitempty"`
+}
+
+// SchemaResponse is the JSON body of the response to a validation request.
+type SchemaResponse struct {
+	Valid      bool               `json:"valid"`
+	Violations []schema.Violation `json:"violations"`
+}
+
+// RegisterSchemaRoutes groups the JSON Schema routes.
+func RegisterSchemaRoutes(e *echo.Echo) *echo.Echo {
+	e.GET("/schema", SchemaPage)
+	e.POST("/api/v1/toml/schema", InferSchema, LimitBody)
+	e.POST("/api/v1/toml/schema/validate", ValidateSchema, LimitBody)
+	return e
+}
+
+// SchemaPage renders the form inferring and validating JSON Schemas.
+func SchemaPage(c echo.Context) error {
+	page := component.Schema()
+	return page.Render(c.Request().Context(), c.Response().Writer)
+}
+
+// InferSchema responds with the JSON Schema inferred from the TOML document.
+// Form submissions get the schema rendered into the schema field.
+func InferSchema(c echo.Context) error {
+	req, err := bindSchema(c)
+	if err != nil {
+		return err
+	}
+	doc, err := eval.Decode(req.TOMLData)
+	if err != nil {
+		return &echo.HTTPError{
+			Code:     http.StatusUnprocessableEntity,
+			Message:  "Unprocessable entity",
+			Internal: err,
+		}
+	}
+	s := schema.Infer(doc.Data())
+	if isJSON(c) {
+		return c.JSON(http.StatusOK, s)
+	}
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	field := component.SchemaInput(string(out))
+	return field.Render(c.Request().Context(), c.Response().Writer)
+}
+
+// ValidateSchema validates the TOML document against the JSON Schema and
+// lists the violations with the tq query paths of the offending values.
+func ValidateSchema(c echo.Context) error {
+	req, err := bindSchema(c)
+	if err != nil {
+		return err
+	}
+	s, err := schema.Parse(req.Schema)
+	if err != nil {
+		return &echo.HTTPError{
+			Code:     http.StatusBadRequest,
+			Message:  "Bad request",
+			Internal: err,
+		}
+	}
+	doc, err := eval.Decode(req.TOMLData)
+	if err != nil {
+		return &echo.HTTPError{
+			Code:     http.StatusUnprocessableEntity,
+			Message:  "Unprocessable entity",
+			Internal: err,
+		}
+	}
+	violations := s.Validate(doc.Data())
+	if isJSON(c) {
+		return c.JSON(http.StatusOK, SchemaResponse{
+			Valid:      len(violations) == 0,
+			Violations: violations,
+		})
+	}
+	list := component.Violations(violations)
+	return list.Render(c.Request().Context(), c.Response().Writer)
+}
+
+// bindSchema reads the TOML document and the schema from either the JSON body
+// or the form fields tomlData and schema. It always returns HTTP errors.
+func bindSchema(c echo.Context) (SchemaRequest, error) {
+	var req SchemaRequest
+	if !isJSON(c) {
+		input, err := TOMLInput(c)
+		if err != nil {
+			return req, err
+		}
+		return SchemaRequest{TOMLData: input, Schema: json.RawMessage(c.FormValue("schema"))}, nil
+	}
+	if err := (&echo.DefaultBinder{}).BindBody(c, &req); err != nil {
+		return req, &echo.HTTPError{
+			Code:     http.StatusBadRequest,
+			Message:  "Bad request",
+			Internal: err,
+		}
+	}
+	input, err := upload.Normalize([]byte(req.TOMLData))
+	if err != nil {
+		return req, uploadError(err)
+	}
+	req.TOMLData = input
+	return req, nil
+}
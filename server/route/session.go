@@ -0,0 +1,32 @@
+package route
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mdm-code/tqweb/server/cookie"
+)
+
+const (
+	sessionCookie = "tqweb_session"
+	sessionMaxAge = 365 * 24 * time.Hour
+)
+
+// SessionID returns the ID of the anonymous client session kept in a signed
+// cookie. A new session is started when the cookie is missing or invalid.
+func SessionID(c echo.Context) string {
+	if id, ok := c.Get(sessionCookie).(string); ok {
+		return id
+	}
+	if id, err := cookie.Default.Read(c, sessionCookie); err == nil && id != "" {
+		return id
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+	cookie.Default.Write(c, sessionCookie, id, sessionMaxAge)
+	c.Set(sessionCookie, id)
+	return id
+}
@@ -0,0 +1,91 @@
+package route
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mdm-code/tqweb/server/history"
+	"github.com/mdm-code/tqweb/server/snippet"
+	"github.com/mdm-code/tqweb/server/upload"
+)
+
+const (
+	snippetsKey  = "tqweb_snippets"
+	historiesKey = "tqweb_histories"
+)
+
+// UseSnippets makes the routes save snippets in the store and the query
+// histories of the client sessions in the book. The routes working with
+// snippets and histories expect it to be installed, as server.Server does.
+func UseSnippets(store snippet.Store, book *history.Book) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(snippetsKey, store)
+			c.Set(historiesKey, book)
+			return next(c)
+		}
+	}
+}
+
+func snippets(c echo.Context) snippet.Store {
+	return c.Get(snippetsKey).(snippet.Store)
+}
+
+func histories(c echo.Context) *history.Book {
+	return c.Get(historiesKey).(*history.Book)
+}
+
+// SnippetResponse is the JSON body of the response to saving a snippet.
+type SnippetResponse struct {
+	snippet.Snippet
+	URL string `json:"url"`
+}
+
+// SaveSnippet saves the playground state as a snippet and responds with the
+// link restoring it. Form submissions save the first query of the form.
+func SaveSnippet(c echo.Context) error {
+	var s snippet.Snippet
+	if isJSON(c) {
+		if err := (&echo.DefaultBinder{}).BindBody(c, &s); err != nil {
+			return &echo.HTTPError{
+				Code:     http.StatusBadRequest,
+				Message:  "Bad request",
+				Internal: err,
+			}
+		}
+		input, err := upload.Normalize([]byte(s.Input))
+		if err != nil {
+			return uploadError(err)
+		}
+		s = snippet.Snippet{Title: s.Title, Query: s.Query, Input: input, Options: s.Options}
+	} else {
+		input, err := TOMLInput(c)
+		if err != nil {
+			return err
+		}
+		s = snippet.Snippet{
+			Title:   c.FormValue("title"),
+			Query:   c.FormValue("tqQuery"),
+			Input:   input,
+			Options: FormOptions(c),
+		}
+	}
+	s, err := snippets(c).Put(s)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, SnippetResponse{Snippet: s, URL: "/?snippet=" + s.ID})
+}
+
+// GetSnippet responds with the saved snippet.
+func GetSnippet(c echo.Context) error {
+	s, err := snippets(c).Get(c.Param("id"))
+	if err != nil {
+		return &echo.HTTPError{
+			Code:     http.StatusNotFound,
+			Message:  "Not found",
+			Internal: err,
+		}
+	}
+	return c.JSON(http.StatusOK, s)
+}
@@ -0,0 +1,71 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mdm-code/tqweb/server/codegen"
+	"github.com/mdm-code/tqweb/server/component"
+	"github.com/mdm-code/tqweb/server/upload"
+)
+
+// StructsRequest is the JSON body of a request generating Go types from a
+// TOML document.
+type StructsRequest struct {
+	TOMLData string          `json:"tomlData"`
+	Options  codegen.Options `json:"options"`
+}
+
+// StructsPage renders the form generating Go types from a TOML document.
+func StructsPage(c echo.Context) error {
+	page := component.Structs()
+	return page.Render(c.Request().Context(), c.Response().Writer)
+}
+
+// GenerateStructs generates Go types with toml struct tags for the TOML
+// document.
+func GenerateStructs(c echo.Context) error {
+	var req StructsRequest
+	if isJSON(c) {
+		if err := (&echo.DefaultBinder{}).BindBody(c, &req); err != nil {
+			return &echo.HTTPError{
+				Code:     http.StatusBadRequest,
+				Message:  "Bad request",
+				Internal: err,
+			}
+		}
+		input, err := upload.Normalize([]byte(req.TOMLData))
+		if err != nil {
+			return uploadError(err)
+		}
+		req.TOMLData = input
+	} else {
+		input, err := TOMLInput(c)
+		if err != nil {
+			return err
+		}
+		req = StructsRequest{
+			TOMLData: input,
+			Options: codegen.Options{
+				Package:  c.FormValue("package"),
+				Root:     c.FormValue("root"),
+				Naming:   c.FormValue("naming"),
+				Pointers: c.FormValue("pointers") == "true",
+			},
+		}
+	}
+	result, err := codegen.Generate(req.TOMLData, req.Options)
+	if err != nil {
+		code, message := http.StatusUnprocessableEntity, "Unprocessable entity"
+		if errors.Is(err, codegen.ErrOptions) {
+			code, message = http.StatusBadRequest, "Bad request"
+		}
+		return &echo.HTTPError{Code: code, Message: message, Internal: err}
+	}
+	if isJSON(c) {
+		return c.JSON(http.StatusOK, result)
+	}
+	generated := component.Generated(result)
+	return generated.Render(c.Request().Context(), c.Response().Writer)
+}
@@ -0,0 +1,96 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mdm-code/tqweb/server/component"
+	"github.com/mdm-code/tqweb/server/upload"
+)
+
+// MaxBodySize is the size limit of the body of a request carrying a TOML
+// document. It leaves room for the other fields of the request, and it is
+// the size net/http caps URL-encoded forms to as well.
+const MaxBodySize = upload.MaxSize + 2<<20
+
+// maxMemory is the part of a multipart form kept in memory, as echo parses
+// it, the rest being stored in temporary files.
+const maxMemory = 32 << 20
+
+// LimitBody rejects request bodies over MaxBodySize while they are read,
+// rather than once a whole upload has been parsed. Forms are parsed up front
+// so that a form over the limit is reported instead of being taken for an
+// empty one.
+func LimitBody(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		r := c.Request()
+		r.Body = http.MaxBytesReader(c.Response(), r.Body, MaxBodySize)
+		var err error
+		switch ct := r.Header.Get(echo.HeaderContentType); {
+		case strings.HasPrefix(ct, echo.MIMEMultipartForm):
+			err = r.ParseMultipartForm(maxMemory)
+		case strings.HasPrefix(ct, echo.MIMEApplicationForm):
+			err = r.ParseForm()
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return uploadError(upload.ErrTooLarge)
+		}
+		return next(c)
+	}
+}
+
+// UploadTOML reads an uploaded TOML file and renders the TOML INPUT field
+// filled with its normalized contents. Errors are retargeted at the output
+// panel so that the input field is left in place.
+func UploadTOML(c echo.Context) error {
+	input, err := TOMLInput(c)
+	if err != nil {
+		c.Response().Header().Set("HX-Retarget", "#output")
+		return err
+	}
+	field := component.TOMLInput(input)
+	return field.Render(c.Request().Context(), c.Response().Writer)
+}
+
+// TOMLInput reads the TOML input of the request. The document is read from
+// the tomlFile upload stream when the request carries one, and from the
+// tomlData form field otherwise.
+func TOMLInput(c echo.Context) (string, error) {
+	input, err := tomlInput(c)
+	if err != nil {
+		return "", uploadError(err)
+	}
+	return input, nil
+}
+
+// uploadError turns the error of reading a TOML document into an HTTP error.
+func uploadError(err error) *echo.HTTPError {
+	if errors.Is(err, upload.ErrTooLarge) {
+		return &echo.HTTPError{
+			Code:     http.StatusRequestEntityTooLarge,
+			Message:  "Request entity too large",
+			Internal: err,
+		}
+	}
+	return &echo.HTTPError{
+		Code:     http.StatusUnprocessableEntity,
+		Message:  "Unprocessable entity",
+		Internal: err,
+	}
+}
+
+func tomlInput(c echo.Context) (string, error) {
+	fh, err := c.FormFile("tomlFile")
+	if err != nil {
+		return upload.Normalize([]byte(c.FormValue("tomlData")))
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return upload.Read(f)
+}
@@ -0,0 +1,62 @@
+package route_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/mdm-code/tqweb/server"
+	"github.com/mdm-code/tqweb/server/route"
+	"github.com/mdm-code/tqweb/server/upload"
+)
+
+func TestTOMLInputTooLarge(t *testing.T) {
+	c := newClient(server.Server())
+	form := url.Values{"tqQuery": {"."}, "tomlData": {strings.Repeat("x", upload.MaxSize+1)}}
+	if rec := c.form(http.MethodPost, "/api/v1/inputData", form); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+// endless reads as an endless run of the byte.
+type endless byte
+
+func (b endless) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(b)
+	}
+	return len(p), nil
+}
+
+// TestUploadTooLarge streams a file that never ends. The request only
+// completes if the size limit applies while the body is read.
+func TestUploadTooLarge(t *testing.T) {
+	const boundary = "tqweb"
+	head := "--" + boundary + "\r\n" +
+		`Content-Disposition: form-data; name="tomlFile"; filename="a.toml"` + "\r\n" +
+		"Content-Type: application/toml\r\n\r\n"
+	e := server.Server()
+	for _, target := range []string{"/api/v1/toml/upload", "/api/v1/inputData", "/api/v1/documents"} {
+		body := io.MultiReader(strings.NewReader(head), endless('x'))
+		req := httptest.NewRequest(http.MethodPost, target, body)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEMultipartForm+"; boundary="+boundary)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: status %d, want %d", target, rec.Code, http.StatusRequestEntityTooLarge)
+		}
+	}
+}
+
+func TestFormTooLarge(t *testing.T) {
+	c := newClient(server.Server())
+	form := url.Values{"tqQuery": {"."}, "tomlData": {strings.Repeat("x", route.MaxBodySize)}}
+	if rec := c.form(http.MethodPost, "/api/v1/inputData", form); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+}
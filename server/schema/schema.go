@@ -0,0 +1,472 @@
+/*
+Package schema infers JSON Schemas from TOML documents and validates TOML
+documents against them.
+
+Only a subset of JSON Schema draft 2020-12 is supported: the type, enum,
+const, properties, required, additionalProperties, items, minItems, maxItems,
+minLength, maxLength, pattern, minimum, maximum and format keywords along with
+boolean schemas. Other keywords are ignored. The date-time, date and time
+formats are asserted rather than treated as annotations, so TOML datetimes can
+be told apart from plain strings. TOML local datetimes, which have no JSON
+Schema format, get the date-time-local format of this package. TOML datetimes
+are strings for the purpose of validation.
+*/
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"time"
+
+	gotoml "github.com/pelletier/go-toml/v2"
+
+	"github.com/mdm-code/tqweb/server/diff"
+)
+
+// Draft is the JSON Schema dialect of inferred schemas.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Formats of the TOML datetimes.
+const (
+	DateTime = "date-time"
+	Date     = "date"
+	Time     = "time"
+
+	// LocalDateTime is the format of TOML local datetimes, which JSON Schema
+	// does not define.
+	LocalDateTime = "date-time-local"
+)
+
+// ErrSchema is returned for schemas that cannot be used for validation.
+var ErrSchema = errors.New("invalid schema")
+
+// Schema is a JSON Schema. A schema decoded from the JSON literal true or
+// false is a boolean schema accepting every or no value respectively. The
+// const keyword is kept as raw JSON so that a const of null can be told
+// apart from a missing one.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Type                 Types              `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Const                json.RawMessage    `json:"const,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+
+	// Bool holds the value of a boolean schema.
+	Bool *bool `json:"-"`
+}
+
+// Types is the value of the type keyword. It is encoded as a single string
+// when it holds one type.
+type Types []string
+
+// MarshalJSON encodes a single type as a string.
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// UnmarshalJSON decodes the type keyword given either as a string or as an
+// array of strings.
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = Types{s}
+		return nil
+	}
+	var ss []string
+	if err := json.Unmarshal(data, &ss); err != nil {
+		return err
+	}
+	*t = ss
+	return nil
+}
+
+// MarshalJSON encodes boolean schemas as JSON literals.
+func (s Schema) MarshalJSON() ([]byte, error) {
+	if s.Bool != nil {
+		return json.Marshal(*s.Bool)
+	}
+	type schema Schema
+	return json.Marshal(schema(s))
+}
+
+// UnmarshalJSON decodes both object and boolean schemas.
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		*s = Schema{Bool: &b}
+		return nil
+	}
+	type schema Schema
+	var v schema
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*s = Schema(v)
+	return nil
+}
+
+// Parse decodes a JSON Schema and compiles its patterns to check they are
+// valid.
+func Parse(data []byte) (*Schema, error) {
+	var s Schema
+	d := json.NewDecoder(bytes.NewReader(data))
+	if err := d.Decode(&s); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSchema, err)
+	}
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *Schema) check() error {
+	if s == nil {
+		return nil
+	}
+	if s.Pattern != "" {
+		if _, err := regexp.Compile(s.Pattern); err != nil {
+			return fmt.Errorf("%w: %w", ErrSchema, err)
+		}
+	}
+	for _, t := range s.Type {
+		switch t {
+		case "object", "array", "string", "integer", "number", "boolean", "null":
+		default:
+			return fmt.Errorf("%w: unknown type %q", ErrSchema, t)
+		}
+	}
+	for _, p := range s.Properties {
+		if err := p.check(); err != nil {
+			return err
+		}
+	}
+	if err := s.AdditionalProperties.check(); err != nil {
+		return err
+	}
+	return s.Items.check()
+}
+
+// Infer returns the schema of the decoded TOML document. Every key of a
+// table is required. The items of an array share a single schema where the
+// keys of tables missing from some of the items are optional.
+func Infer(data any) *Schema {
+	s := infer(data)
+	s.Schema = Draft
+	return s
+}
+
+func infer(v any) *Schema {
+	switch v := v.(type) {
+	case map[string]any:
+		s := &Schema{Type: Types{"object"}, Properties: make(map[string]*Schema, len(v))}
+		for k, e := range v {
+			s.Properties[k] = infer(e)
+			s.Required = append(s.Required, k)
+		}
+		slices.Sort(s.Required)
+		return s
+	case []any:
+		s := &Schema{Type: Types{"array"}}
+		for _, e := range v {
+			s.Items = merge(s.Items, infer(e))
+		}
+		return s
+	case time.Time:
+		return &Schema{Type: Types{"string"}, Format: DateTime}
+	case gotoml.LocalDate:
+		return &Schema{Type: Types{"string"}, Format: Date}
+	case gotoml.LocalTime:
+		return &Schema{Type: Types{"string"}, Format: Time}
+	case gotoml.LocalDateTime:
+		return &Schema{Type: Types{"string"}, Format: LocalDateTime}
+	case float64:
+		return &Schema{Type: Types{"number"}}
+	}
+	return &Schema{Type: Types{typeOf(v)}}
+}
+
+// merge returns a schema accepting the values of both schemas.
+func merge(a, b *Schema) *Schema {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if reflect.DeepEqual(a, b) {
+		return a
+	}
+	if len(a.Type) == 1 && len(b.Type) == 1 && a.Type[0] == b.Type[0] {
+		switch a.Type[0] {
+		case "object":
+			s := &Schema{Type: a.Type, Properties: make(map[string]*Schema)}
+			for k, p := range a.Properties {
+				s.Properties[k] = merge(p, b.Properties[k])
+			}
+			for k, p := range b.Properties {
+				if _, ok := s.Properties[k]; !ok {
+					s.Properties[k] = p
+				}
+			}
+			for _, k := range a.Required {
+				if slices.Contains(b.Required, k) {
+					s.Required = append(s.Required, k)
+				}
+			}
+			return s
+		case "array":
+			return &Schema{Type: a.Type, Items: merge(a.Items, b.Items)}
+		}
+		return &Schema{Type: a.Type}
+	}
+	types := slices.Clone(a.Type)
+	for _, t := range b.Type {
+		if !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+	slices.Sort(types)
+	return &Schema{Type: types}
+}
+
+// Violation is a value of the document that does not satisfy the schema.
+type Violation struct {
+	Path    string `json:"path"`
+	Keyword string `json:"keyword"`
+	Detail  string `json:"detail"`
+}
+
+// Validate reports the values of the decoded TOML document violating the
+// schema. Each violation carries the tq query path of the value.
+func (s *Schema) Validate(data any) []Violation {
+	var vs []Violation
+	s.validate("", data, &vs)
+	return vs
+}
+
+func (s *Schema) validate(path string, v any, vs *[]Violation) {
+	if s == nil {
+		return
+	}
+	report := func(keyword, format string, args ...any) {
+		p := path
+		if p == "" {
+			p = "."
+		}
+		*vs = append(*vs, Violation{Path: p, Keyword: keyword, Detail: fmt.Sprintf(format, args...)})
+	}
+	if s.Bool != nil {
+		if !*s.Bool {
+			report("false", "no value is allowed here")
+		}
+		return
+	}
+	if len(s.Type) > 0 && !slices.ContainsFunc(s.Type, func(t string) bool { return is(t, v) }) {
+		report("type", "expected %s but got %s", joinTypes(s.Type), typeOf(v))
+		return
+	}
+	if s.Const != nil {
+		var c any
+		if err := json.Unmarshal(s.Const, &c); err == nil && !equal(c, v) {
+			report("const", "expected %s", literal(c))
+		}
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return equal(e, v) }) {
+		report("enum", "expected one of %s", literal(s.Enum))
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		for _, k := range s.Required {
+			if _, ok := v[k]; !ok {
+				report("required", "missing required key %q", k)
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			if p, ok := s.Properties[k]; ok {
+				p.validate(path+diff.Key(k), v[k], vs)
+			} else {
+				s.AdditionalProperties.validate(path+diff.Key(k), v[k], vs)
+			}
+		}
+	case []any:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			report("minItems", "expected at least %d items but got %d", *s.MinItems, len(v))
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			report("maxItems", "expected at most %d items but got %d", *s.MaxItems, len(v))
+		}
+		for i, e := range v {
+			s.Items.validate(path+diff.Index(i), e, vs)
+		}
+	case string:
+		n := len([]rune(v))
+		if s.MinLength != nil && n < *s.MinLength {
+			report("minLength", "expected at least %d characters but got %d", *s.MinLength, n)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			report("maxLength", "expected at most %d characters but got %d", *s.MaxLength, n)
+		}
+		if s.Pattern != "" {
+			if re, err := regexp.Compile(s.Pattern); err == nil && !re.MatchString(v) {
+				report("pattern", "%q does not match %q", v, s.Pattern)
+			}
+		}
+	}
+	if s.Format != "" && !formatted(s.Format, v) {
+		report("format", "expected a %s", s.Format)
+	}
+	if f, ok := number(v); ok {
+		if s.Minimum != nil && f < *s.Minimum {
+			report("minimum", "expected at least %v but got %v", *s.Minimum, f)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			report("maximum", "expected at most %v but got %v", *s.Maximum, f)
+		}
+	}
+}
+
+// typeOf returns the JSON Schema type of the decoded TOML value.
+func typeOf(v any) string {
+	switch v := v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string, time.Time, gotoml.LocalDate, gotoml.LocalTime, gotoml.LocalDateTime:
+		return "string"
+	case int64:
+		return "integer"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "null"
+}
+
+func is(t string, v any) bool {
+	got := typeOf(v)
+	return got == t || t == "number" && got == "integer"
+}
+
+// formatted reports whether the value is in the format. Values of other
+// types than strings are ignored.
+func formatted(format string, v any) bool {
+	var err error
+	switch v := v.(type) {
+	case time.Time:
+		return format == DateTime
+	case gotoml.LocalDate:
+		return format == Date
+	case gotoml.LocalTime:
+		return format == Time
+	case gotoml.LocalDateTime:
+		return format == LocalDateTime
+	case string:
+		switch format {
+		case DateTime:
+			_, err = time.Parse(time.RFC3339Nano, v)
+		case Date:
+			_, err = time.Parse(time.DateOnly, v)
+		case Time:
+			var t gotoml.LocalTime
+			err = t.UnmarshalText([]byte(v))
+		case LocalDateTime:
+			var t gotoml.LocalDateTime
+			err = t.UnmarshalText([]byte(v))
+		}
+	}
+	return err == nil
+}
+
+func number(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// equal compares a value decoded from the JSON schema with a decoded TOML
+// value. Numbers are compared by value and datetimes by their string form.
+func equal(schema, v any) bool {
+	if f, ok := number(v); ok {
+		g, ok := schema.(float64)
+		return ok && f == g
+	}
+	switch v.(type) {
+	case time.Time:
+		t, err := time.Parse(time.RFC3339Nano, fmt.Sprint(schema))
+		return err == nil && t.Equal(v.(time.Time))
+	case gotoml.LocalDate, gotoml.LocalTime, gotoml.LocalDateTime:
+		return fmt.Sprint(v) == schema
+	case map[string]any:
+		m, ok := schema.(map[string]any)
+		if !ok || len(m) != len(v.(map[string]any)) {
+			return false
+		}
+		for k, e := range v.(map[string]any) {
+			if !equal(m[k], e) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		a, ok := schema.([]any)
+		if !ok || len(a) != len(v.([]any)) {
+			return false
+		}
+		for i, e := range v.([]any) {
+			if !equal(a[i], e) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(schema, v)
+}
+
+func joinTypes(types Types) string {
+	if len(types) == 1 {
+		return types[0]
+	}
+	return literal(types)
+}
+
+func literal(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,197 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/mdm-code/tqweb/server/schema"
+)
+
+func decode(t *testing.T, input string) any {
+	t.Helper()
+	doc, err := eval.Decode(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc.Data()
+}
+
+// canonical re-encodes the JSON so that documents can be compared regardless
+// of the order of their keys.
+func canonical(t *testing.T, data []byte) string {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestInfer(t *testing.T) {
+	tests := []struct {
+		name, input string
+		want        string
+	}{
+		{
+			"scalars",
+			"i = 1\nf = 1.5\ns = \"x\"\nb = true\n",
+			`{"type": "object", "required": ["b", "f", "i", "s"], "properties": {
+				"i": {"type": "integer"}, "f": {"type": "number"},
+				"s": {"type": "string"}, "b": {"type": "boolean"}}}`,
+		},
+		{
+			"empty array",
+			"a = []\n",
+			`{"type": "object", "required": ["a"], "properties": {"a": {"type": "array"}}}`,
+		},
+		{
+			"mixed array",
+			"a = [1, \"x\", 2.5, 2]\n",
+			`{"type": "object", "required": ["a"], "properties": {
+				"a": {"type": "array", "items": {"type": ["integer", "number", "string"]}}}}`,
+		},
+		{
+			"mixed nested arrays",
+			"a = [[1], [\"x\"], []]\n",
+			`{"type": "object", "required": ["a"], "properties": {
+				"a": {"type": "array", "items": {"type": "array", "items": {"type": ["integer", "string"]}}}}}`,
+		},
+		{
+			"optional keys in an array of tables",
+			"[[t]]\nname = \"a\"\nport = 1\n\n[[t]]\nname = \"b\"\nhost = \"h\"\n",
+			`{"type": "object", "required": ["t"], "properties": {
+				"t": {"type": "array", "items": {"type": "object", "required": ["name"], "properties": {
+					"name": {"type": "string"}, "port": {"type": "integer"}, "host": {"type": "string"}}}}}}`,
+		},
+		{
+			"datetimes",
+			"odt = 1979-05-27T07:32:00Z\nldt = 1979-05-27T07:32:00\nld = 1979-05-27\nlt = 07:32:00\n",
+			`{"type": "object", "required": ["ld", "ldt", "lt", "odt"], "properties": {
+				"odt": {"type": "string", "format": "date-time"},
+				"ldt": {"type": "string", "format": "date-time-local"},
+				"ld": {"type": "string", "format": "date"},
+				"lt": {"type": "string", "format": "time"}}}`,
+		},
+		{
+			"datetimes of different formats",
+			"a = [1979-05-27, 07:32:00]\nb = [1979-05-27, 1980-01-01]\n",
+			`{"type": "object", "required": ["a", "b"], "properties": {
+				"a": {"type": "array", "items": {"type": "string"}},
+				"b": {"type": "array", "items": {"type": "string", "format": "date"}}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := schema.Infer(decode(t, tt.input))
+			if s.Schema != schema.Draft {
+				t.Errorf("$schema %q, want %q", s.Schema, schema.Draft)
+			}
+			s.Schema = ""
+			b, err := json.Marshal(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if have, want := canonical(t, b), canonical(t, []byte(tt.want)); have != want {
+				t.Errorf("have %s\nwant %s", have, want)
+			}
+			if vs := schema.Infer(decode(t, tt.input)).Validate(decode(t, tt.input)); len(vs) > 0 {
+				t.Errorf("the document violates its own schema: %v", vs)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		keyword, schema, input string
+		paths                  []string
+	}{
+		{"type", `{"properties": {"a": {"type": "string"}}}`, "a = 1\n", []string{`["a"]`}},
+		{"type", `{"properties": {"a": {"type": ["string", "number"]}}}`, "a = 1\nb = 2\n", nil},
+		{"type", `{"type": "array"}`, "a = 1\n", []string{"."}},
+		{"enum", `{"properties": {"a": {"enum": ["x", "y"]}}}`, "a = \"z\"\n", []string{`["a"]`}},
+		{"enum", `{"properties": {"a": {"enum": [1, 2]}}}`, "a = 2\n", nil},
+		{"const", `{"properties": {"a": {"const": {"b": [1]}}}}`, "a = {b = [2]}\n", []string{`["a"]`}},
+		{"const", `{"properties": {"a": {"const": {"b": [1]}}}}`, "a = {b = [1]}\n", nil},
+		{"const", `{"properties": {"a": {"const": "1979-05-27T07:32:00Z"}}}`, "a = 1979-05-27T07:32:00Z\n", nil},
+		{"const", `{"properties": {"a": {"const": null}}}`, "a = 0\n", []string{`["a"]`}},
+		{"required", `{"required": ["a", "b"]}`, "a = 1\n", []string{"."}},
+		{"required", `{"properties": {"t": {"required": ["name"]}}}`, "[t]\nport = 1\n", []string{`["t"]`}},
+		{"false", `{"properties": {"a": true}, "additionalProperties": false}`, "a = 1\nb = 2\n", []string{`["b"]`}},
+		{"type", `{"additionalProperties": {"type": "integer"}}`, "a = 1\nb = \"x\"\n", []string{`["b"]`}},
+		{"type", `{"properties": {"a": {"items": {"type": "integer"}}}}`, "a = [1, \"x\", 2, \"y\"]\n", []string{`["a"][1]`, `["a"][3]`}},
+		{"minItems", `{"properties": {"a": {"minItems": 2}}}`, "a = [1]\n", []string{`["a"]`}},
+		{"maxItems", `{"properties": {"a": {"maxItems": 1}}}`, "a = [1, 2]\n", []string{`["a"]`}},
+		{"minLength", `{"properties": {"a": {"minLength": 3}}}`, "a = \"żó\"\n", []string{`["a"]`}},
+		{"maxLength", `{"properties": {"a": {"maxLength": 2}}}`, "a = \"żół\"\n", []string{`["a"]`}},
+		{"maxLength", `{"properties": {"a": {"maxLength": 2}}}`, "a = \"żó\"\n", nil},
+		{"pattern", `{"properties": {"a": {"pattern": "^[a-z]+$"}}}`, "a = \"abc1\"\n", []string{`["a"]`}},
+		{"minimum", `{"properties": {"a": {"minimum": 1.5}}}`, "a = 1\nb = 2\n", []string{`["a"]`}},
+		{"maximum", `{"properties": {"a": {"maximum": 1}}}`, "a = 1.5\n", []string{`["a"]`}},
+		{"format", `{"properties": {"a": {"format": "date"}}}`, "a = 1979-05-27T07:32:00Z\n", []string{`["a"]`}},
+		{"format", `{"properties": {"a": {"format": "date-time"}}}`, "a = \"1979-05-27T07:32:00Z\"\n", nil},
+		{"format", `{"properties": {"a": {"format": "time"}}}`, "a = \"7 o'clock\"\n", []string{`["a"]`}},
+		{"format", `{"properties": {"a": {"format": "date-time-local"}}}`, "a = 1979-05-27T07:32:00\n", nil},
+		{"false", `false`, "a = 1\n", []string{"."}},
+		{"type", `{"properties": {"a.b": {"properties": {"c d": {"type": "string"}}}}}`, "[\"a.b\"]\n\"c d\" = 1\n", []string{`["a.b"]["c d"]`}},
+	}
+	for _, tt := range tests {
+		s, err := schema.Parse([]byte(tt.schema))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.schema, err)
+		}
+		var paths []string
+		for _, v := range s.Validate(decode(t, tt.input)) {
+			if v.Keyword != tt.keyword {
+				t.Errorf("%s: keyword %s, want %s", tt.schema, v.Keyword, tt.keyword)
+			}
+			paths = append(paths, v.Path)
+		}
+		if !slices.Equal(paths, tt.paths) {
+			t.Errorf("%s against %q: paths %q, want %q", tt.schema, tt.input, paths, tt.paths)
+		}
+	}
+}
+
+func TestConstNull(t *testing.T) {
+	for _, tt := range []struct {
+		schema string
+		want   string
+	}{
+		{`{"const": null}`, `{"const":null}`},
+		{`{}`, `{}`},
+	} {
+		s, err := schema.Parse([]byte(tt.schema))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("%s encodes as %s, want %s", tt.schema, b, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, data := range []string{
+		`{"type": "map"}`,
+		`{"pattern": "("}`,
+		`{"properties": {"a": {"items": {"pattern": "["}}}}`,
+		`{"type": 1}`,
+		`[]`,
+	} {
+		if _, err := schema.Parse([]byte(data)); !errors.Is(err, schema.ErrSchema) {
+			t.Errorf("Parse(%s) = %v, want ErrSchema", data, err)
+		}
+	}
+}
@@ -1,15 +1,160 @@
 package server
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/mdm-code/tqweb/server/browse"
+	"github.com/mdm-code/tqweb/server/cache"
+	"github.com/mdm-code/tqweb/server/component"
+	"github.com/mdm-code/tqweb/server/docstore"
+	"github.com/mdm-code/tqweb/server/history"
+	"github.com/mdm-code/tqweb/server/i18n"
+	"github.com/mdm-code/tqweb/server/live"
 	"github.com/mdm-code/tqweb/server/route"
+	"github.com/mdm-code/tqweb/server/snippet"
 )
 
+// ErrConfig is returned for invalid server configuration.
+var ErrConfig = errors.New("invalid configuration")
+
+// Option registers additional routes with the HTTP server.
+type Option func(*echo.Echo) *echo.Echo
+
 // Server provides a dummy HTTP server.
-func Server() *echo.Echo {
+func Server(opts ...Option) *echo.Echo {
 	e := echo.New()
 	e.Use(middleware.Logger())
+	e.Use(route.Language)
+	e.Use(route.UseSnippets(snippet.NewMemory(snippet.DefaultMemoryBytes), history.New(history.MaxEntries)))
+	e.Use(route.UseResults(cache.New(cache.DefaultMaxBytes, cache.DefaultTTL)))
+	e.Use(route.UseDocuments(docstore.New(docstore.DefaultMaxBytes, docstore.DefaultTTL)))
+	e.Use(route.UseLiveSessions(live.NewSessions()))
+	e.HTTPErrorHandler = ErrorHandler(e)
 	e = route.RegisterAll(e)
+	for _, opt := range opts {
+		e = opt(e)
+	}
 	return e
 }
+
+// WithRoot lets the HTTP server browse the TOML files under the root
+// directory.
+func WithRoot(root string) (Option, error) {
+	t, err := browse.New(root)
+	if err != nil {
+		return nil, err
+	}
+	return route.BrowseRoutes(t), nil
+}
+
+// WithSnippets saves snippets as files in the directory rather than in
+// memory, and query histories in its history subdirectory.
+func WithSnippets(dir string) (Option, error) {
+	s, err := snippet.NewDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	b, err := history.NewDir(filepath.Join(dir, "history"), history.MaxEntries)
+	if err != nil {
+		return nil, err
+	}
+	return func(e *echo.Echo) *echo.Echo {
+		e.Use(route.UseSnippets(s, b))
+		return e
+	}, nil
+}
+
+// WithFrameAncestors lets the sources, separated by spaces, frame the
+// embedded snippets. Sources are given as in the frame-ancestors directive of
+// the Content Security Policy, for example https://wiki.example.com or
+// 'none'.
+func WithFrameAncestors(sources string) (Option, error) {
+	fields := strings.Fields(sources)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%w: no frame ancestors", ErrConfig)
+	}
+	for _, f := range fields {
+		if strings.ContainsAny(f, ";,") {
+			return nil, fmt.Errorf("%w: invalid frame ancestor %q", ErrConfig, f)
+		}
+	}
+	return func(e *echo.Echo) *echo.Echo {
+		e.Use(route.UseFrameAncestors(fields))
+		return e
+	}, nil
+}
+
+// ErrorHandler renders errors as HTML fragments for htmx requests. Other
+// requests get the errors caused by their input as JSON diagnostics and fall
+// back on the default echo error handler otherwise. The fragments name the
+// status in the language of the request.
+func ErrorHandler(e *echo.Echo) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		if c.Response().Committed {
+			e.DefaultHTTPErrorHandler(err, c)
+			return
+		}
+		if c.Request().Header.Get("HX-Request") == "" {
+			if d, ok := diagnostic(c, err); ok && c.Request().Method != http.MethodHead {
+				if err := c.JSON(d.Code, d.ErrorResponse); err != nil {
+					c.Logger().Error(err)
+				}
+				return
+			}
+			e.DefaultHTTPErrorHandler(err, c)
+			return
+		}
+		code, message, detail := http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), ""
+		var he *echo.HTTPError
+		if errors.As(err, &he) {
+			code = he.Code
+			if m, ok := he.Message.(string); ok {
+				message = m
+			}
+			if he.Internal != nil {
+				detail = he.Internal.Error()
+			}
+		}
+		if key := fmt.Sprintf("status.%d", code); i18n.Has(key) {
+			message = i18n.T(i18n.FromContext(c.Request().Context()), key)
+		}
+		c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
+		c.Response().WriteHeader(code)
+		if err := component.Error(message, detail).Render(c.Request().Context(), c.Response().Writer); err != nil {
+			c.Logger().Error(err)
+		}
+	}
+}
+
+type diagnosed struct {
+	route.ErrorResponse
+	Code int
+}
+
+// diagnostic describes the HTTP error caused by the input of the request
+// along with the error underneath it.
+func diagnostic(c echo.Context, err error) (diagnosed, bool) {
+	he, ok := err.(*echo.HTTPError)
+	if !ok || he.Internal == nil {
+		return diagnosed{}, false
+	}
+	message, ok := he.Message.(string)
+	if _, nested := he.Internal.(*echo.HTTPError); !ok || nested {
+		return diagnosed{}, false
+	}
+	detail := he.Internal.Error()
+	return diagnosed{
+		ErrorResponse: route.ErrorResponse{
+			Message:     message,
+			Detail:      detail,
+			Explanation: i18n.Explain(i18n.FromContext(c.Request().Context()), detail),
+		},
+		Code: he.Code,
+	}, true
+}
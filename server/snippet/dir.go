@@ -0,0 +1,123 @@
+package snippet
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var validID = regexp.MustCompile(`^[0-9A-Za-z_-]+$`)
+
+// Dir is a store keeping every snippet in a JSON file of its own in a
+// directory, so that snippets outlive the server and can be managed from the
+// command line.
+type Dir struct {
+	mu   sync.RWMutex
+	path string
+}
+
+// NewDir returns a store backed by the directory, creating it if needed.
+func NewDir(path string) (*Dir, error) {
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, err
+	}
+	return &Dir{path: path}, nil
+}
+
+// Put implements Store.
+func (d *Dir) Put(s Snippet) (Snippet, error) {
+	s = fill(s)
+	if !validID.MatchString(s.ID) {
+		return Snippet{}, ErrInvalidID
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return Snippet{}, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	tmp, err := os.CreateTemp(d.path, ".snippet-*")
+	if err != nil {
+		return Snippet{}, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return Snippet{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return Snippet{}, err
+	}
+	return s, os.Rename(tmp.Name(), d.file(s.ID))
+}
+
+// Get implements Store.
+func (d *Dir) Get(id string) (Snippet, error) {
+	if !validID.MatchString(id) {
+		return Snippet{}, ErrNotFound
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.read(d.file(id))
+}
+
+// List implements Store.
+func (d *Dir) List() ([]Snippet, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return nil, err
+	}
+	var list []Snippet
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		s, err := d.read(filepath.Join(d.path, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	sortByCreated(list)
+	return list, nil
+}
+
+// Delete implements Store.
+func (d *Dir) Delete(id string) error {
+	if !validID.MatchString(id) {
+		return ErrNotFound
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	err := os.Remove(d.file(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (d *Dir) file(id string) string {
+	return filepath.Join(d.path, id+".json")
+}
+
+func (d *Dir) read(path string) (Snippet, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Snippet{}, ErrNotFound
+	}
+	if err != nil {
+		return Snippet{}, err
+	}
+	var s Snippet
+	if err := json.Unmarshal(b, &s); err != nil {
+		return Snippet{}, err
+	}
+	return s, nil
+}
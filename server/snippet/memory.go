@@ -0,0 +1,106 @@
+package snippet
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+)
+
+// DefaultMemoryBytes is the default size budget of the snippets kept in
+// memory.
+const DefaultMemoryBytes = 64 << 20
+
+// Memory is a store keeping snippets in memory until the server stops. Once
+// the snippets outgrow the size budget of the store, the ones created first
+// are dropped to make room for new ones.
+type Memory struct {
+	mu       sync.RWMutex
+	max      int
+	bytes    int
+	snippets map[string]Snippet
+}
+
+// NewMemory returns an empty in-memory store keeping up to maxBytes of
+// snippet titles, queries and inputs.
+func NewMemory(maxBytes int) *Memory {
+	return &Memory{max: maxBytes, snippets: make(map[string]Snippet)}
+}
+
+// Put implements Store.
+func (m *Memory) Put(s Snippet) (Snippet, error) {
+	s = fill(s)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if old, ok := m.snippets[s.ID]; ok {
+		m.bytes -= size(old)
+	}
+	m.snippets[s.ID] = s
+	m.bytes += size(s)
+	for m.bytes > m.max && len(m.snippets) > 1 {
+		m.dropOldest(s.ID)
+	}
+	return s, nil
+}
+
+// dropOldest removes the snippet created first other than the one with the
+// kept ID. It must be called with the lock held.
+func (m *Memory) dropOldest(kept string) {
+	var oldest Snippet
+	for id, s := range m.snippets {
+		if id != kept && (oldest.ID == "" || s.Created.Before(oldest.Created)) {
+			oldest = s
+		}
+	}
+	m.bytes -= size(oldest)
+	delete(m.snippets, oldest.ID)
+}
+
+// size is the number of bytes the snippet takes up in the budget.
+func size(s Snippet) int {
+	return len(s.Title) + len(s.Query) + len(s.Input)
+}
+
+// Get implements Store.
+func (m *Memory) Get(id string) (Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.snippets[id]
+	if !ok {
+		return Snippet{}, ErrNotFound
+	}
+	return s, nil
+}
+
+// List implements Store.
+func (m *Memory) List() ([]Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := make([]Snippet, 0, len(m.snippets))
+	for _, s := range m.snippets {
+		list = append(list, s)
+	}
+	sortByCreated(list)
+	return list, nil
+}
+
+// Delete implements Store.
+func (m *Memory) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.snippets[id]
+	if !ok {
+		return ErrNotFound
+	}
+	m.bytes -= size(s)
+	delete(m.snippets, id)
+	return nil
+}
+
+func sortByCreated(list []Snippet) {
+	slices.SortFunc(list, func(a, b Snippet) int {
+		if c := b.Created.Compare(a.Created); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+}
@@ -0,0 +1,69 @@
+package snippet_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mdm-code/tqweb/server/snippet"
+)
+
+func TestMemory(t *testing.T) {
+	m := snippet.NewMemory(snippet.DefaultMemoryBytes)
+	s, err := m.Put(snippet.Snippet{Query: ".", Input: "a = 1\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ID == "" || s.Created.IsZero() {
+		t.Errorf("ID and creation time not filled in: %+v", s)
+	}
+	if got, err := m.Get(s.ID); err != nil || got != s {
+		t.Errorf("Get() = %+v, %v", got, err)
+	}
+	if err := m.Delete(s.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get(s.ID); !errors.Is(err, snippet.ErrNotFound) {
+		t.Errorf("Get() after Delete() = %v, want ErrNotFound", err)
+	}
+	if err := m.Delete(s.ID); !errors.Is(err, snippet.ErrNotFound) {
+		t.Errorf("Delete() twice = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryDropsOldest(t *testing.T) {
+	m := snippet.NewMemory(25)
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	put := func(id string, age time.Duration) {
+		t.Helper()
+		s := snippet.Snippet{ID: id, Query: ".", Input: strings.Repeat("x", 9), Created: created.Add(-age)}
+		if _, err := m.Put(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("old", 2*time.Hour)
+	put("older", 3*time.Hour)
+	// Putting a snippet again does not count it twice.
+	put("old", 2*time.Hour)
+	put("new", time.Hour)
+	list, err := m.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, s := range list {
+		ids = append(ids, s.ID)
+	}
+	if strings.Join(ids, " ") != "new old" {
+		t.Errorf("kept %v, want [new old]", ids)
+	}
+
+	// A snippet over the budget is kept alone.
+	if _, err := m.Put(snippet.Snippet{ID: "large", Input: strings.Repeat("x", 30)}); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := m.List(); len(list) != 1 || list[0].ID != "large" {
+		t.Errorf("kept %v, want only the large snippet", list)
+	}
+}
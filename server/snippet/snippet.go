@@ -0,0 +1,83 @@
+/*
+Package snippet stores playground states, a query with its input and output
+options, so that they can be restored, shared and embedded later. Snippets are
+identified by a hash of their contents, so saving the same state twice yields
+the same snippet.
+*/
+package snippet
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mdm-code/tqweb/server/eval"
+)
+
+var (
+	// ErrNotFound is returned for unknown snippet IDs.
+	ErrNotFound = errors.New("snippet not found")
+
+	// ErrInvalidID is returned for snippet IDs other than letters, digits,
+	// dashes and underscores.
+	ErrInvalidID = errors.New("invalid snippet ID")
+)
+
+// Snippet is a saved playground state.
+type Snippet struct {
+	ID      string       `json:"id"`
+	Title   string       `json:"title,omitempty"`
+	Query   string       `json:"query"`
+	Input   string       `json:"input"`
+	Options eval.Options `json:"options"`
+	Created time.Time    `json:"created"`
+}
+
+// DisplayTitle returns the title of the snippet or, for untitled snippets,
+// one made up of its ID.
+func (s Snippet) DisplayTitle() string {
+	if s.Title != "" {
+		return s.Title
+	}
+	return "tqweb snippet " + s.ID
+}
+
+// NewID returns the ID of the snippet with the given contents.
+func NewID(query, input string, o eval.Options) string {
+	h := sha256.New()
+	opts, _ := json.Marshal(o)
+	for _, part := range []string{query, input, string(opts)} {
+		fmt.Fprintf(h, "%d:%s", len(part), part)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// Store is a snippet store backend.
+type Store interface {
+	// Put saves the snippet and returns it with its ID and creation time
+	// filled in.
+	Put(s Snippet) (Snippet, error)
+
+	// Get returns the snippet with the ID.
+	Get(id string) (Snippet, error)
+
+	// List returns all snippets ordered from the most recently created.
+	List() ([]Snippet, error)
+
+	// Delete removes the snippet with the ID.
+	Delete(id string) error
+}
+
+// fill sets the ID and the creation time of a snippet about to be saved.
+func fill(s Snippet) Snippet {
+	if s.ID == "" {
+		s.ID = NewID(s.Query, s.Input, s.Options)
+	}
+	if s.Created.IsZero() {
+		s.Created = time.Now().UTC()
+	}
+	return s
+}
@@ -0,0 +1,79 @@
+/*
+Package upload reads TOML documents uploaded to tqweb. It bounds the size of
+the document and brings its encoding to the form the TOML decoder expects: the
+UTF-8 byte order mark is stripped, CRLF line endings are normalized to LF, and
+UTF-16 documents and documents with NUL characters are rejected with an
+explanation rather than a decoding error.
+*/
+package upload
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+// MaxSize is the size limit of a single uploaded TOML document.
+const MaxSize = 8 << 20
+
+var (
+	// ErrTooLarge is returned when a document exceeds MaxSize.
+	ErrTooLarge = errors.New("the TOML document is larger than 8 MiB")
+
+	// ErrUTF16 is returned for documents encoded in UTF-16.
+	ErrUTF16 = errors.New("the TOML document is encoded in UTF-16; TOML documents must be UTF-8, save the file as UTF-8 and upload it again")
+
+	// ErrNotUTF8 is returned for documents that are not valid UTF-8.
+	ErrNotUTF8 = errors.New("the TOML document is not valid UTF-8")
+
+	// ErrNUL is returned for UTF-8 documents containing NUL characters.
+	ErrNUL = errors.New("the TOML document contains a NUL character, which TOML does not allow")
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+)
+
+// Read reads the TOML document from the reader and normalizes it.
+func Read(r io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > MaxSize {
+		return "", ErrTooLarge
+	}
+	return Normalize(data)
+}
+
+// Normalize checks the size and the encoding of the TOML document, strips
+// the UTF-8 byte order mark and converts CRLF line endings to LF.
+func Normalize(data []byte) (string, error) {
+	switch {
+	case len(data) > MaxSize:
+		return "", ErrTooLarge
+	case bytes.HasPrefix(data, bomUTF16BE), bytes.HasPrefix(data, bomUTF16LE):
+		return "", ErrUTF16
+	case bytes.IndexByte(data, 0) >= 0:
+		if utf16(data) {
+			return "", ErrUTF16
+		}
+		return "", ErrNUL
+	}
+	data = bytes.TrimPrefix(data, bomUTF8)
+	if !utf8.Valid(data) {
+		return "", ErrNotUTF8
+	}
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	return string(data), nil
+}
+
+// utf16 reports whether the document without a byte order mark looks like
+// UTF-16 text. TOML documents start with an ASCII character, which UTF-16
+// encodes as a NUL byte next to the character itself.
+func utf16(data []byte) bool {
+	return len(data) >= 2 && (data[0] == 0) != (data[1] == 0)
+}
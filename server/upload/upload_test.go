@@ -0,0 +1,61 @@
+package upload_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/mdm-code/tqweb/server/upload"
+)
+
+// encodeUTF16 encodes the text in UTF-16 with the byte order and, if bom is
+// set, with the byte order mark.
+func encodeUTF16(s string, bigEndian, bom bool) []byte {
+	var out []byte
+	units := utf16.Encode([]rune(s))
+	if bom {
+		units = append([]uint16{0xFEFF}, units...)
+	}
+	for _, u := range units {
+		if bigEndian {
+			out = append(out, byte(u>>8), byte(u))
+		} else {
+			out = append(out, byte(u), byte(u>>8))
+		}
+	}
+	return out
+}
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		data  []byte
+		input string
+		err   error
+	}{
+		{"plain", []byte("a = 1\n"), "a = 1\n", nil},
+		{"bom", []byte("\xEF\xBB\xBFa = 1\r\n"), "a = 1\n", nil},
+		{"utf16be bom", encodeUTF16("a = 1\n", true, true), "", upload.ErrUTF16},
+		{"utf16le bom", encodeUTF16("a = 1\n", false, true), "", upload.ErrUTF16},
+		{"utf16be", encodeUTF16("a = 1\n", true, false), "", upload.ErrUTF16},
+		{"utf16le", encodeUTF16("a = 1\n", false, false), "", upload.ErrUTF16},
+		{"nul", []byte("a = \"x\x00y\"\n"), "", upload.ErrNUL},
+		{"not utf8", []byte("a = \"\xff\"\n"), "", upload.ErrNotUTF8},
+		{"too large", []byte(strings.Repeat("#", upload.MaxSize+1)), "", upload.ErrTooLarge},
+	} {
+		input, err := upload.Normalize(tc.data)
+		if input != tc.input || !errors.Is(err, tc.err) {
+			t.Errorf("%s: Normalize() = %q, %v, want %q, %v", tc.name, input, err, tc.input, tc.err)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	if _, err := upload.Read(strings.NewReader(strings.Repeat("#", upload.MaxSize+1))); !errors.Is(err, upload.ErrTooLarge) {
+		t.Errorf("Read() = %v, want ErrTooLarge", err)
+	}
+	if input, err := upload.Read(strings.NewReader("a = 1\r\n")); input != "a = 1\n" || err != nil {
+		t.Errorf("Read() = %q, %v", input, err)
+	}
+}
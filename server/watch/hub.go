@@ -0,0 +1,70 @@
+package watch
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mdm-code/tqweb/server/eval"
+)
+
+type key struct {
+	path  string
+	query string
+	opts  eval.Options
+}
+
+type entry struct {
+	watcher *Watcher
+	refs    int
+	cancel  context.CancelFunc
+}
+
+// Hub shares watchers between the subscribers watching the same file with
+// the same query.
+type Hub struct {
+	interval time.Duration
+
+	mu       sync.Mutex
+	watchers map[key]*entry
+}
+
+// NewHub returns a hub polling watched files at the given interval.
+func NewHub(interval time.Duration) *Hub {
+	return &Hub{
+		interval: interval,
+		watchers: make(map[key]*entry),
+	}
+}
+
+// Subscribe subscribes to the results of the query run against the file at
+// path. The watcher is started with the first subscription and stopped when
+// the last subscription is cancelled.
+func (h *Hub) Subscribe(path, query string, o eval.Options) (<-chan Result, func()) {
+	k := key{path: path, query: query, opts: o}
+	h.mu.Lock()
+	e, ok := h.watchers[k]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		e = &entry{watcher: New(path, query, o), cancel: cancel}
+		h.watchers[k] = e
+		go e.watcher.Run(ctx, h.interval)
+	}
+	e.refs++
+	h.mu.Unlock()
+
+	ch, unsubscribe := e.watcher.Subscribe()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			unsubscribe()
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			e.refs--
+			if e.refs == 0 {
+				e.cancel()
+				delete(h.watchers, k)
+			}
+		})
+	}
+}
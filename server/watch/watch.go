@@ -0,0 +1,148 @@
+/*
+Package watch re-runs a tq query whenever the TOML file it is saved for
+changes. Files are polled for their modification time and size, and their
+contents are hashed to tell real changes from a mere touch, so no file system
+notification library is needed. Results are broadcast to any number of
+subscribers, and a watcher stops polling once its last subscriber is gone.
+*/
+package watch
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/mdm-code/tqweb/server/upload"
+)
+
+// DefaultInterval is the default polling interval of watched files.
+const DefaultInterval = time.Second
+
+// Result is the output or the error of the query after the latest change.
+type Result struct {
+	Output string
+	Error  string
+}
+
+// Watcher polls a single file and runs the saved query when it changes.
+type Watcher struct {
+	path  string
+	query string
+	opts  eval.Options
+
+	mu      sync.Mutex
+	subs    map[chan Result]struct{}
+	last    *Result
+	modTime time.Time
+	size    int64
+	sum     [sha256.Size]byte
+}
+
+// New returns a watcher for the file at path running the query on change.
+func New(path, query string, o eval.Options) *Watcher {
+	return &Watcher{
+		path:  path,
+		query: query,
+		opts:  o,
+		subs:  make(map[chan Result]struct{}),
+	}
+}
+
+// Subscribe returns the channel the results are delivered to along with the
+// function cancelling the subscription. The latest result, if there is one,
+// is delivered right away. Slow subscribers only get the most recent result.
+func (w *Watcher) Subscribe() (<-chan Result, func()) {
+	ch := make(chan Result, 1)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subs[ch] = struct{}{}
+	if w.last != nil {
+		ch <- *w.last
+	}
+	return ch, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if _, ok := w.subs[ch]; ok {
+			delete(w.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Run polls the file at the given interval until the context is done.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	w.Poll()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.Poll()
+		}
+	}
+}
+
+// Poll checks the file for changes and broadcasts the query result if the
+// file has changed since the last poll.
+func (w *Watcher) Poll() {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		w.publish(Result{Error: err.Error()}, [sha256.Size]byte{})
+		return
+	}
+	w.mu.Lock()
+	unchanged := w.last != nil && info.ModTime().Equal(w.modTime) && info.Size() == w.size
+	w.modTime, w.size = info.ModTime(), info.Size()
+	w.mu.Unlock()
+	if unchanged {
+		return
+	}
+	f, err := os.Open(w.path)
+	if err != nil {
+		w.publish(Result{Error: err.Error()}, [sha256.Size]byte{})
+		return
+	}
+	input, err := upload.Read(f)
+	f.Close()
+	if err != nil {
+		w.publish(Result{Error: err.Error()}, [sha256.Size]byte{})
+		return
+	}
+	sum := sha256.Sum256([]byte(input))
+	w.mu.Lock()
+	same := w.last != nil && sum == w.sum
+	w.mu.Unlock()
+	if same {
+		return
+	}
+	var r Result
+	r.Output, err = eval.Run(w.query, input, w.opts)
+	if err != nil {
+		r.Error = err.Error()
+	}
+	w.publish(r, sum)
+}
+
+func (w *Watcher) publish(r Result, sum [sha256.Size]byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.last != nil && *w.last == r {
+		w.sum = sum
+		return
+	}
+	w.last, w.sum = &r, sum
+	for ch := range w.subs {
+		// Make room for the latest result if the subscriber has not picked
+		// up the previous one yet.
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- r
+	}
+}
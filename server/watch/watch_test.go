@@ -0,0 +1,134 @@
+package watch_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/mdm-code/tqweb/server/eval"
+	"github.com/mdm-code/tqweb/server/watch"
+)
+
+// file writes the TOML input to a file in a temporary directory.
+func file(t *testing.T, input string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "watched.toml")
+	if err := os.WriteFile(p, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func receive(t *testing.T, ch <-chan watch.Result) watch.Result {
+	t.Helper()
+	select {
+	case r, ok := <-ch:
+		if !ok {
+			t.Fatal("the channel is closed")
+		}
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("no result")
+	}
+	return watch.Result{}
+}
+
+func quiet(t *testing.T, ch <-chan watch.Result) {
+	t.Helper()
+	select {
+	case r := <-ch:
+		t.Errorf("unexpected result %+v", r)
+	default:
+	}
+}
+
+func TestPollPublishesChanges(t *testing.T) {
+	p := file(t, "a = 1\n")
+	w := watch.New(p, `["a"]`, eval.Options{})
+	ch, unsubscribe := w.Subscribe()
+	defer unsubscribe()
+	w.Poll()
+	if r := receive(t, ch); r.Output != "1\n" || r.Error != "" {
+		t.Errorf("first result %+v", r)
+	}
+	if err := os.WriteFile(p, []byte("a = 22\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w.Poll()
+	if r := receive(t, ch); r.Output != "22\n" {
+		t.Errorf("result after a change %+v", r)
+	}
+	if err := os.WriteFile(p, []byte("a = \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w.Poll()
+	if r := receive(t, ch); r.Error == "" {
+		t.Errorf("result of an invalid document %+v", r)
+	}
+
+	late, unsubscribeLate := w.Subscribe()
+	defer unsubscribeLate()
+	if r := receive(t, late); r.Error == "" {
+		t.Errorf("latest result of a new subscriber %+v", r)
+	}
+}
+
+func TestPollIgnoresTouch(t *testing.T) {
+	p := file(t, "a = 1\n")
+	w := watch.New(p, `["a"]`, eval.Options{})
+	ch, unsubscribe := w.Subscribe()
+	defer unsubscribe()
+	w.Poll()
+	receive(t, ch)
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(p, later, later); err != nil {
+		t.Fatal(err)
+	}
+	w.Poll()
+	quiet(t, ch)
+	// Rewriting the file with the same content is not a change either.
+	if err := os.WriteFile(p, []byte("a = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w.Poll()
+	quiet(t, ch)
+}
+
+func TestHub(t *testing.T) {
+	before := runtime.NumGoroutine()
+	p := file(t, "a = 1\n")
+	h := watch.NewHub(time.Millisecond)
+	first, unsubscribeFirst := h.Subscribe(p, `["a"]`, eval.Options{})
+	second, unsubscribeSecond := h.Subscribe(p, `["a"]`, eval.Options{})
+	for _, ch := range []<-chan watch.Result{first, second} {
+		if r := receive(t, ch); r.Output != "1\n" {
+			t.Errorf("first result %+v", r)
+		}
+	}
+
+	unsubscribeFirst()
+	unsubscribeFirst()
+	if _, ok := <-first; ok {
+		t.Error("the channel is open after unsubscribing")
+	}
+	if err := os.WriteFile(p, []byte("a = 22\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if r := receive(t, second); r.Output != "22\n" {
+		t.Errorf("result after a change %+v", r)
+	}
+
+	unsubscribeSecond()
+	if _, ok := <-second; ok {
+		t.Error("the channel is open after unsubscribing")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines left running, %d before subscribing", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}